@@ -12,7 +12,7 @@ import (
 
 type myhook struct{}
 
-func (myhook) Write(ns string, line string, kind clog.Stream) {
+func (myhook) Write(line string, _ *clog.Entry) {
 	fmt.Print(line)
 }
 
@@ -171,7 +171,7 @@ func (args *ArgReader) ID(tile bool) (res string, err error) {
 						char = '_'
 					}
 				}
-			} else {
+			} else if char != '"' && char != '\'' {
 				if !identifierOkFirst.TestRune(rune(char)) {
 					args.Unread()
 					err = io.EOF
@@ -203,6 +203,11 @@ func (args *ArgReader) ID(tile bool) (res string, err error) {
 }
 func (args *ArgReader) Assign(k rune) (r any, e error) {
 	args.Skipspace()
+	if args.Finished() {
+		return nil, io.EOF
+	} else if args.Peek() == '-' {
+		return true, nil // A bare flag followed by another one.
+	}
 	err := args.Take(k)
 	if err != nil {
 		if err == io.EOF {
@@ -337,6 +342,7 @@ func (args *ArgReader) Object() (res map[string]any, e error) {
 			return nil, err
 		}
 		res[key] = val
+		args.TakeIf(',')
 	}
 }
 func (args *ArgReader) Array() (res []any, e error) {
@@ -355,10 +361,12 @@ func (args *ArgReader) Array() (res []any, e error) {
 			return nil, err
 		}
 		res = append(res, val)
+		args.TakeIf(',')
 	}
 }
 
 var numericRunes = util.NewRuneSet("0123456789-+.eExX")
+var unitRunes = util.NewRuneSet(util.RunesAlphanum.String(), "%.")
 
 func (args *ArgReader) Number() (res float64, e error) {
 	result := args.TakeAnyN(&numericRunes)
@@ -381,11 +389,25 @@ func (args *ArgReader) Any() (res any, e error) {
 		return args.Quoted(char)
 	case '-', '+', '.', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		args.Unread()
-		return args.Number()
+		start := args.pos
+		res, e = args.Number()
+		// Numbers followed by a unit such as 10s or 100mb are kept as text.
+		if unitRunes.TestRune(args.Peek()) {
+			args.TakeAnyN(&unitRunes)
+			return string(args.buf[start:args.pos]), nil
+		}
+		return
 	default:
 		if identifierOkFirst.TestRune(rune(char)) {
 			args.Unread()
-			return args.ID(false)
+			res, e = args.ID(false)
+			switch res {
+			case "true":
+				return true, e
+			case "false":
+				return false, e
+			}
+			return
 		}
 		return nil, fmt.Errorf("unexpected character %c", char)
 	}
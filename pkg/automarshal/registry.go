@@ -48,6 +48,9 @@ type WithDefaults interface {
 type WithDefaultsID interface {
 	WithDefaults(ID)
 }
+type WithValidation interface {
+	Validate() error
+}
 
 func (id ID) String() (r string) {
 	if id.ID == "" {
@@ -140,6 +143,11 @@ func (r *Registry[W, I]) Define(kind string, defaults any) {
 		if result, ok := result.Interface().(WithDefaults); ok {
 			result.WithDefaults()
 		}
+		if result, ok := result.Interface().(WithValidation); ok {
+			if err := result.Validate(); err != nil {
+				return nil, err
+			}
+		}
 		return result.Interface(), nil
 	}
 }
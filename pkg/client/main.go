@@ -1,15 +1,20 @@
 package client
 
 import (
+	"context"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net/url"
 	"os"
 	"os/exec"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/can1357/gosu/pkg/automarshal"
 	"github.com/can1357/gosu/pkg/client/view"
@@ -17,13 +22,54 @@ import (
 	"github.com/can1357/gosu/pkg/session"
 	"github.com/can1357/gosu/pkg/settings"
 	"github.com/can1357/gosu/pkg/surpc"
+	"github.com/can1357/gosu/pkg/util"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/samber/lo"
 )
 
 var commands = map[string]func(body, flags string) error{}
 
+// Flags of each command that take a value, "-s HUP" is read as "-s=HUP" for these.
+var commandValues = map[string]map[string]bool{}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Collects the names of the fields of a flag struct that take a value.
+func valuedFlags(t reflect.Type, res map[string]bool) map[string]bool {
+	if t.Kind() != reflect.Struct {
+		return res
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			valuedFlags(f.Type, res)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		} else if name == "" {
+			name = f.Name
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			res[strings.ToLower(name)] = true
+		default:
+			if reflect.PointerTo(ft).Implements(textUnmarshaler) {
+				res[strings.ToLower(name)] = true
+			}
+		}
+	}
+	return res
+}
+
 func addCommand[T any](name any, fn func(body string, arg T) error) {
+	valued := valuedFlags(reflect.TypeOf((*T)(nil)).Elem(), map[string]bool{})
 	fnw := func(body, c string) error {
 		var arg T
 		err := automarshal.NewArgReader(c).Unmarshal(&arg)
@@ -35,15 +81,33 @@ func addCommand[T any](name any, fn func(body string, arg T) error) {
 	switch name := name.(type) {
 	case string:
 		commands[name] = fnw
+		commandValues[name] = valued
 	case []string:
 		for _, n := range name {
 			commands[n] = fnw
+			commandValues[n] = valued
 		}
 	default:
 		panic(fmt.Errorf("invalid command name: %v", name))
 	}
 }
 
+// Returns true if the flag value has to be quoted to be read back as a string.
+func quoteValue(v string) bool {
+	if v == "" || strings.ContainsAny(v[:1], `{["'`) {
+		return false
+	}
+	return strings.IndexFunc(v, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-:.%+$", r)
+	}) >= 0
+}
+
+var argEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func quoteArg(v string) string {
+	return `"` + argEscaper.Replace(v) + `"`
+}
+
 var client *surpc.Client
 
 func getClient() *surpc.Client {
@@ -60,7 +124,7 @@ func getClient() *surpc.Client {
 				}
 				time.Sleep(100 * time.Millisecond)
 			}
-			fmt.Printf("Started daemon %d\n", daemon.Process.Pid)
+			fmt.Fprintf(lo.Ternary[io.Writer](machineOutput(), os.Stderr, os.Stdout), "Started daemon %d\n", daemon.Process.Pid)
 			daemon.Process.Release()
 		}
 	}
@@ -72,11 +136,28 @@ func Call(method string, reply any, arg any) error {
 
 func Run() {
 	display := func(jobs []session.RpcJobInfo, e error) {
+		if failed(e) {
+			return
+		}
 		list := view.NewTasklist(func() ([]session.RpcJobInfo, error) { return jobs, e })
 		fmt.Print(list.View())
 	}
+	listJobs := func(p string, wide bool) (res session.RpcSessionJobs, err error) {
+		err = Call(lo.Ternary(wide, "job.ListWide", "job.List"), &res, p)
+		if err == nil && len(res.Jobs) == 0 && filters(p) {
+			setExit(ExitNoMatch)
+		}
+		return
+	}
 
 	addCommand([]string{"view", "v"}, func(p string, _ struct{}) error {
+		if machineOutput() {
+			res, err := listJobs(p, false)
+			if err != nil || !emit(res.Jobs, jobIDs(res.Jobs)) {
+				display(res.Jobs, err)
+			}
+			return nil
+		}
 		list := view.NewTasklist(func() (info []session.RpcJobInfo, err error) {
 			var res session.RpcSessionJobs
 			err = Call("job.List", &res, p)
@@ -84,14 +165,66 @@ func Run() {
 				info = res.Jobs
 			}
 			return
+		}).WithMetrics(func(match string, since time.Time) (res []session.RpcTaskMetrics, err error) {
+			if match == "" {
+				match = p
+			}
+			err = Call("job.Metrics", &res, session.RpcMetricsQuery{Match: match, Since: since})
+			return
 		})
 		tea.NewProgram(list).Run()
 		return nil
 	})
-	addCommand([]string{"list", "ls"}, func(p string, _ struct{}) error {
-		var res session.RpcSessionJobs
-		err := Call("job.List", &res, p)
-		display(res.Jobs, err)
+	addCommand([]string{"list", "ls"}, func(p string, flags struct {
+		Wide bool `json:"wide"` // Shows the threads, fds, IO, context switches and ports.
+	}) error {
+		wide := flags.Wide || output.Format == OutputWide
+		res, err := listJobs(p, wide)
+		if err == nil && emit(res.Jobs, jobIDs(res.Jobs)) {
+			return nil
+		}
+		if err == nil && wide {
+			fmt.Print(view.NewTasklist(func() ([]session.RpcJobInfo, error) { return res.Jobs, err }).Wide().View())
+		} else {
+			display(res.Jobs, err)
+		}
+		return nil
+	})
+	addCommand("describe", func(p string, flags struct {
+		Json bool `json:"json"` // Prints the description as JSON.
+	}) error {
+		var res []session.RpcJobDescription
+		err := Call("job.Describe", &res, p)
+		if err == nil && len(res) == 0 && filters(p) {
+			setExit(ExitNoMatch)
+		}
+		if flags.Json && output.Format == OutputHuman {
+			output.Format = OutputJson
+		}
+		if failed(err) || emit(res, lo.Map(res, func(d session.RpcJobDescription, _ int) string { return d.ID })) {
+			return nil
+		}
+		fmt.Print(view.RenderDescribe(res, err))
+		return nil
+	})
+	addCommand("history", func(p string, _ struct{}) error {
+		var res []session.RpcExit
+		err := Call("job.History", &res, p)
+		if failed(err) || emit(res, lo.Map(res, func(e session.RpcExit, _ int) string { return e.Job })) {
+			return nil
+		}
+		fmt.Print(view.RenderHistory(res, err))
+		return nil
+	})
+	addCommand("errors", func(p string, flags struct {
+		Full bool `json:"full"` // Shows the whole sample traces.
+	}) error {
+		var res []session.ErrorGroup
+		err := Call("errors.List", &res, p)
+		if failed(err) || emit(res, lo.Map(res, func(e session.ErrorGroup, _ int) string { return e.Job + "/" + e.Fingerprint })) {
+			return nil
+		}
+		fmt.Print(view.RenderErrors(res, flags.Full, err))
 		return nil
 	})
 	addCommand("put", func(jobAndKey string, value any) error {
@@ -104,11 +237,12 @@ func Run() {
 		}
 
 		var count int
-		err := Call("whiteboard.Put", &count, key)
+		err := Call("whiteboard.Put", &count, session.RpcWhiteboardKv{RpcWhiteboardKey: key, Value: lo.Must(json.Marshal(value))})
 		if err != nil {
 			display(nil, err)
+		} else if !emit(map[string]int{"count": count}, nil) {
+			fmt.Printf("Put %d values\n", count)
 		}
-		fmt.Printf("Put %d values\n", count)
 		return nil
 	})
 	addCommand("get", func(jobAndKey string, _ struct{}) error {
@@ -121,9 +255,12 @@ func Run() {
 		}
 		var out []session.RpcWhiteboardKv
 		err := Call("whiteboard.Get", &out, key)
+		if err == nil && len(out) == 0 {
+			setExit(ExitNoMatch)
+		}
 		if err != nil {
 			display(nil, err)
-		} else {
+		} else if !emit(out, lo.Map(out, func(v session.RpcWhiteboardKv, _ int) string { return v.Job + ":" + v.Key })) {
 			for _, v := range out {
 				fmt.Printf("%s:%s: %s\n", v.Job, v.Key, string(v.Value))
 			}
@@ -147,18 +284,53 @@ func Run() {
 		return nil
 	})
 
+	// Creates the jobs one by one, reporting the ones that failed on stderr.
+	createAll := func(cmd string, mfs []job.Manifest) {
+		var jobs []session.RpcJobInfo
+		for _, mf := range mfs {
+			var res session.RpcJobInfo
+			if err := Call(cmd, &res, mf); err != nil {
+				fail(ExitError, fmt.Errorf("%s: %w", mf.ID, err))
+				continue
+			}
+			jobs = append(jobs, res)
+		}
+		if len(jobs) != 0 && !emit(jobs, jobIDs(jobs)) {
+			display(jobs, nil)
+		}
+	}
 	addCreate := func(cmd string, name []string, human string) {
 		addCommand(name, func(body string, mf job.Manifest) error {
+			// PM2 ecosystem files create a job per app, or only the one named by --id.
+			if path, ok := strings.CutPrefix(body, "@"); ok && isEcosystem(path) {
+				mfs, err := readEcosystem(path, mf.Profile)
+				if err == nil && mf.ID != "" {
+					mfs = lo.Filter(mfs, func(m job.Manifest, _ int) bool { return m.ID == mf.ID })
+					if len(mfs) == 0 {
+						err = fmt.Errorf("no app named %s in %s", mf.ID, path)
+					}
+				}
+				if err != nil {
+					display(nil, err)
+				} else {
+					createAll(cmd, mfs)
+				}
+				return nil
+			}
 			err := json.Unmarshal(lo.Must(json.Marshal(body)), &mf.Main)
 			if err != nil {
 				display(nil, err)
 				return nil
 			}
-			js, _ := json.MarshalIndent(mf, "", "  ")
-			fmt.Printf(human, string(js))
+			if !machineOutput() {
+				js, _ := json.MarshalIndent(mf, "", "  ")
+				fmt.Printf(human, string(js))
+			}
 			var res session.RpcJobInfo
 			err = Call(cmd, &res, mf)
-			display([]session.RpcJobInfo{res}, err)
+			if err != nil || !emit(res, []string{res.ID}) {
+				display([]session.RpcJobInfo{res}, err)
+			}
 			return nil
 		})
 	}
@@ -166,21 +338,220 @@ func Run() {
 		addCommand(name, func(p string, _ struct{}) error {
 			var res []string
 			err := Call(cmd, &res, p)
+			if err == nil && len(res) == 0 {
+				setExit(ExitNoMatch)
+			}
 			if err != nil {
 				display(nil, err)
-			} else {
+			} else if !emit(res, res) {
 				fmt.Println(human, strings.Join(res, ","))
 			}
 			return nil
 		})
 	}
+	addCommand("import pm2", func(path string, flags struct {
+		EnvProfile string `json:"env_profile"` // Selects the env_<name> variables of the apps.
+		Dry        bool   `json:"dry"`         // Prints the manifests instead of launching them.
+	}) error {
+		mfs, err := readEcosystem(path, flags.EnvProfile)
+		switch {
+		case err != nil:
+			display(nil, err)
+		case flags.Dry:
+			if !emit(mfs, lo.Map(mfs, func(m job.Manifest, _ int) string { return m.ID })) {
+				js, _ := json.MarshalIndent(mfs, "", "  ")
+				fmt.Println(string(js))
+			}
+		default:
+			createAll("job.Launch", mfs)
+		}
+		return nil
+	})
+	type projectFlags struct {
+		File       string `json:"file"`
+		F          any    `json:"f"`           // The file, either -f=path or -f path.
+		Project    string `json:"project"`     // The name of the project, defaults to the path of the file.
+		EnvProfile string `json:"env_profile"` // The environment profile of the jobs that declare it and do not select one.
+		Force      bool   `json:"force"`       // Adopts the jobs of the same id owned by another project or by none.
+	}
+	projectFile := func(body string, flags projectFlags) string {
+		if f, ok := flags.F.(string); ok {
+			return f
+		}
+		return lo.Ternary(flags.File != "", flags.File, body)
+	}
+	addCommand("up", func(body string, flags projectFlags) error {
+		path, project, err := findProject(projectFile(body, flags), flags.Project)
+		var mfs []job.Manifest
+		if err == nil {
+			mfs, err = readProject(path)
+		}
+		if err != nil {
+			display(nil, err)
+			return nil
+		}
+		// The profile only applies to the jobs that declare it, the others keep their base env.
+		if p := flags.EnvProfile; p != "" {
+			if !lo.ContainsBy(mfs, func(mf job.Manifest) bool { return mf.HasProfile(p) }) {
+				display(nil, fmt.Errorf("no job of the project declares the environment profile %s", p))
+				return nil
+			}
+			for i := range mfs {
+				if mfs[i].Profile == "" && mfs[i].HasProfile(p) {
+					mfs[i].Profile = p
+				}
+			}
+		}
+		var res session.RpcProjectChanges
+		if err = Call("project.Up", &res, session.RpcProject{Name: project, Jobs: mfs, Force: flags.Force}); err != nil {
+			display(nil, err)
+			return nil
+		}
+		ids := append(append(append([]string{}, res.Created...), res.Updated...), res.Unchanged...)
+		if !emit(res, ids) {
+			for _, group := range []struct {
+				human string
+				ids   []string
+			}{
+				{"Created job(s):", res.Created},
+				{"Updated job(s):", res.Updated},
+				{"Unchanged job(s):", res.Unchanged},
+				{"Deleted job(s):", res.Deleted},
+			} {
+				if len(group.ids) != 0 {
+					fmt.Println(group.human, strings.Join(group.ids, ","))
+				}
+			}
+		}
+		return nil
+	})
+	addCommand("down", func(body string, flags projectFlags) error {
+		_, project, err := findProject(projectFile(body, flags), flags.Project)
+		var res []string
+		if err == nil {
+			err = Call("project.Down", &res, project)
+		}
+		if err == nil && len(res) == 0 {
+			setExit(ExitNoMatch)
+		}
+		if err != nil {
+			display(nil, err)
+		} else if !emit(res, res) {
+			fmt.Println("Deleted job(s):", strings.Join(res, ","))
+		}
+		return nil
+	})
 	addCreate("job.Update", []string{"update", "u"}, "Updating job %v\n")
 	addCreate("job.Launch", []string{"launch", "a"}, "Starting job %v\n")
 	addCtl("job.Start", []string{"start", "s"}, "Started job(s):")
 	addCtl("job.Stop", []string{"stop", "x"}, "Stopped job(s):")
 	addCtl("job.Restart", []string{"restart", "r"}, "Restarted job(s):")
-	addCtl("job.Kill", []string{"kill", "k"}, "Killed job(s):")
+	addCtl("job.Reload", []string{"reload"}, "Reloaded job(s):")
+	addCommand([]string{"kill", "k"}, func(p string, flags struct {
+		Signal string `json:"signal"`
+		S      string `json:"s"`
+	}) error {
+		var res []string
+		var err error
+		if sig := lo.Ternary(flags.Signal != "", flags.Signal, flags.S); sig != "" {
+			err = Call("job.Signal", &res, session.RpcSignal{Match: p, Signal: sig})
+			if err == nil && !emit(res, res) {
+				fmt.Printf("Sent %s to job(s): %s\n", sig, strings.Join(res, ","))
+			}
+		} else {
+			err = Call("job.Kill", &res, p)
+			if err == nil && !emit(res, res) {
+				fmt.Println("Killed job(s):", strings.Join(res, ","))
+			}
+		}
+		if err == nil && len(res) == 0 {
+			setExit(ExitNoMatch)
+		}
+		if err != nil {
+			display(nil, err)
+		}
+		return nil
+	})
 	addCtl("job.Delete", []string{"delete", "d"}, "Deleted job(s):")
+	addCtl("job.RotateLogs", []string{"logs rotate"}, "Rotated logs of job(s):")
+	addCommand("logs search", func(query string, flags struct {
+		Job    string `json:"job"`
+		Regex  bool   `json:"regex"`
+		Since  string `json:"since"`
+		Until  string `json:"until"`
+		Stream string `json:"stream"`
+		Limit  int    `json:"limit"`
+	}) error {
+		args := session.RpcLogSearch{
+			Match:  flags.Job,
+			Query:  query,
+			Regex:  flags.Regex,
+			Stream: flags.Stream,
+			Limit:  flags.Limit,
+		}
+		var err error
+		if flags.Since != "" {
+			if args.Since, err = util.ParseTime(flags.Since); err != nil {
+				return err
+			}
+		}
+		if flags.Until != "" {
+			if args.Until, err = util.ParseTime(flags.Until); err != nil {
+				return err
+			}
+		}
+		var res []session.RpcLogMessage
+		if err = Call("logs.Search", &res, args); err != nil {
+			display(nil, err)
+			return nil
+		}
+		if emit(res, lo.Map(res, func(m session.RpcLogMessage, _ int) string { return m.Line })) {
+			return nil
+		}
+		var printer view.LogPrinter
+		for _, msg := range res {
+			fmt.Print(printer.Render(msg))
+		}
+		return nil
+	})
+	addCommand("logs", func(p string, flags struct {
+		Follow bool   `json:"follow"`
+		F      bool   `json:"f"`
+		Lines  *int   `json:"lines"`
+		Stream string `json:"stream"`
+		Grep   string `json:"grep"`
+		Since  string `json:"since"`
+	}) error {
+		q := url.Values{}
+		q.Set("q", p)
+		if flags.Lines != nil {
+			q.Set("t", strconv.Itoa(*flags.Lines))
+		}
+		if !flags.Follow && !flags.F {
+			q.Set("f", "0")
+		}
+		q.Set("s", flags.Stream)
+		q.Set("g", flags.Grep)
+		q.Set("since", flags.Since)
+		body, err := getClient().Stream(context.Background(), "/logs", q)
+		if err != nil {
+			display(nil, err)
+			return nil
+		}
+		defer body.Close()
+		var printer view.LogPrinter
+		dec := json.NewDecoder(body)
+		for {
+			var msg session.RpcLogMessage
+			if err := dec.Decode(&msg); err != nil {
+				break
+			}
+			if !emitStream(msg, msg.Line) {
+				fmt.Print(printer.Render(msg))
+			}
+		}
+		return nil
+	})
 
 	cmd := ""
 	args := ""
@@ -188,24 +559,42 @@ func Run() {
 	if len(os.Args) > 1 {
 		cmd = os.Args[1]
 		cmd = strings.ToLower(cmd)
+		rest, err := parseOutputFlags(os.Args[2:])
+		if err != nil {
+			fail(ExitUsage, err)
+			os.Exit(exitCode)
+		}
+		if len(rest) > 0 {
+			if sub := cmd + " " + strings.ToLower(rest[0]); commands[sub] != nil {
+				cmd, rest = sub, rest[1:]
+			}
+		}
 		argString := strings.Builder{}
-		for _, arg := range os.Args[2:] {
+		for i := 0; i < len(rest); i++ {
+			arg := rest[i]
 			if !strings.HasPrefix(arg, "-") {
 				if body == "" {
 					body = arg
 				}
 				continue
 			}
-			if sp := strings.Index(arg, " "); sp >= 0 {
-				if i := strings.IndexByte(arg, '='); i >= 0 && i < sp {
+			// A flag that takes a value reads it from the next argument if it is not assigned.
+			if !strings.Contains(arg, "=") && i+1 < len(rest) && !strings.HasPrefix(rest[i+1], "-") &&
+				commandValues[cmd][strings.ToLower(strings.TrimLeft(arg, "-"))] {
+				i++
+				arg += "=" + rest[i]
+			}
+			if i := strings.IndexByte(arg, '='); i >= 0 && quoteValue(arg[i+1:]) {
+				argString.WriteString(arg[:i+1])
+				argString.WriteString(quoteArg(arg[i+1:]))
+			} else if i >= 0 && strings.IndexAny(arg[i+1:], "{[") == 0 {
+				argString.WriteString(arg) // Objects and arrays are parsed as they are.
+			} else if sp := strings.Index(arg, " "); sp >= 0 {
+				if i >= 0 && i < sp {
 					argString.WriteString(arg[:i+1])
-					argString.WriteString(`"`)
-					argString.WriteString(strings.ReplaceAll(arg[i+1:], `"`, `\"`))
-					argString.WriteString(`"`)
+					argString.WriteString(quoteArg(arg[i+1:]))
 				} else {
-					argString.WriteString(`"`)
-					argString.WriteString(strings.ReplaceAll(arg, `"`, `\"`))
-					argString.WriteString(`"`)
+					argString.WriteString(quoteArg(arg))
 				}
 			} else {
 				argString.WriteString(arg)
@@ -216,11 +605,11 @@ func Run() {
 	}
 
 	if fn, ok := commands[cmd]; ok {
-		err := fn(body, args)
-		if err != nil {
-			log.Fatal(err)
+		if err := fn(body, args); err != nil {
+			fail(ExitUsage, err)
 		}
 	} else {
-		log.Printf("Unknown command: %s\n", cmd)
+		fail(ExitUsage, fmt.Errorf("unknown command: %s", cmd))
 	}
+	os.Exit(exitCode)
 }
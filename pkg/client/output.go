@@ -0,0 +1,155 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/can1357/gosu/pkg/session"
+	"github.com/goccy/go-yaml"
+	"github.com/samber/lo"
+)
+
+// Exit codes of the client, stable for use in scripts.
+const (
+	ExitOk      = 0
+	ExitError   = 1 // The daemon returned an error.
+	ExitUsage   = 2 // Unknown command or invalid arguments.
+	ExitNoMatch = 3 // No job matched the pattern.
+)
+
+const (
+	OutputHuman = ""
+	OutputWide  = "wide"
+	OutputJson  = "json"
+	OutputYaml  = "yaml"
+)
+
+// The output flags shared by every command.
+var output struct {
+	Format string // One of the output formats.
+	Quiet  bool   // Only prints the identifiers of the results.
+}
+
+var exitCode = ExitOk
+
+// Records the exit code of the command, the first failure wins.
+func setExit(code int) {
+	if exitCode == ExitOk {
+		exitCode = code
+	}
+}
+
+// Extracts the output flags from the arguments.
+func parseOutputFlags(args []string) (rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		name, value, assigned := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch strings.ToLower(name) {
+		case "output", "o":
+			if !assigned && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch value = strings.ToLower(value); value {
+			case OutputWide, OutputJson, OutputYaml:
+				output.Format = value
+			default:
+				return nil, fmt.Errorf("invalid output format: %q, expected json, yaml or wide", value)
+			}
+		case "quiet", "q":
+			output.Quiet = value == "" || value == "true"
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return
+}
+
+// Whether the output is meant for scripts rather than humans.
+func machineOutput() bool {
+	return output.Quiet || output.Format == OutputJson || output.Format == OutputYaml
+}
+
+// Prints the result in the requested format, or only its identifiers if quiet. Returns false
+// if the result should be rendered for humans instead.
+func emit(v any, ids []string) bool {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		v = []any{}
+	}
+	switch {
+	case output.Quiet:
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+	case output.Format == OutputJson:
+		js, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Println(string(js))
+	case output.Format == OutputYaml:
+		js, err := json.Marshal(v)
+		if err == nil {
+			js, err = yaml.JSONToYAML(js)
+		}
+		if err != nil {
+			fail(ExitError, err)
+			return true
+		}
+		fmt.Print(string(js))
+	default:
+		return false
+	}
+	return true
+}
+
+// Reports the failure of the command on stderr.
+func fail(code int, err error) {
+	setExit(code)
+	fmt.Fprintln(os.Stderr, "error:", err)
+}
+
+// Prints a single message of a stream, as a JSON line or a YAML document. Returns false if
+// the message should be rendered for humans instead.
+func emitStream(v any, id string) bool {
+	switch {
+	case output.Quiet:
+		fmt.Println(id)
+	case output.Format == OutputJson:
+		js, _ := json.Marshal(v)
+		fmt.Println(string(js))
+	case output.Format == OutputYaml:
+		fmt.Println("---")
+		return emit(v, nil)
+	default:
+		return false
+	}
+	return true
+}
+
+// Records the failure of an RPC. Returns true if it was reported on stderr, leaving nothing
+// for the caller to render.
+func failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	if machineOutput() {
+		fail(ExitError, err)
+		return true
+	}
+	setExit(ExitError)
+	return false
+}
+
+// Whether the pattern selects some of the jobs rather than all of them.
+func filters(match string) bool {
+	return match != "" && match != "*" && match != "all" && match != ".*"
+}
+
+func jobIDs(jobs []session.RpcJobInfo) []string {
+	return lo.Map(jobs, func(j session.RpcJobInfo, _ int) string { return j.ID })
+}
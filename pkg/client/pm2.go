@@ -0,0 +1,36 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/can1357/gosu/pkg/job"
+)
+
+// Whether the file is named like a PM2 ecosystem file, e.g. ecosystem.config.js.
+func isEcosystem(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "ecosystem.")
+}
+
+// Reads the apps of a PM2 ecosystem file as manifests, printing what could not be mapped.
+func readEcosystem(path, profile string) ([]job.Manifest, error) {
+	if path == "" {
+		return nil, errors.New("missing ecosystem file")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	eco, err := job.ReadPm2Ecosystem(path)
+	if err != nil {
+		return nil, err
+	}
+	mfs, warnings, err := eco.Manifests(filepath.Dir(path), profile)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
+	return mfs, err
+}
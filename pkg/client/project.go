@@ -0,0 +1,59 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/can1357/gosu/pkg/job"
+)
+
+// The project files looked for in the working directory, in order.
+var projectFiles = []string{
+	"gosu.config.ts", "gosu.config.js", "gosu.config.mjs", "gosu.config.cjs",
+	"gosu.config.json", "gosu.config.yaml", "gosu.config.yml", "gosu.config.toml",
+}
+
+// Resolves the project file and the name of the project, which defaults to the absolute path
+// of the file. The file is empty if none was given and there is none in the working directory.
+func findProject(file, name string) (path, project string, err error) {
+	if file == "" {
+		for _, f := range projectFiles {
+			if _, err := os.Stat(f); err == nil {
+				file = f
+				break
+			}
+		}
+	}
+	if file != "" {
+		if path, err = filepath.Abs(file); err != nil {
+			return
+		}
+	}
+	if project = name; project == "" {
+		project = path
+	}
+	if project == "" {
+		err = errors.New("no project file, pass one with -f or name the project with --project")
+	}
+	return
+}
+
+// Reads the jobs of the project file, relative paths are resolved against its directory.
+func readProject(path string) (mfs []job.Manifest, err error) {
+	if path == "" {
+		return nil, errors.New("no project file, pass one with -f or add a gosu.config.ts")
+	}
+	if isEcosystem(path) {
+		return readEcosystem(path, "")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	if err = os.Chdir(filepath.Dir(path)); err != nil {
+		return
+	}
+	defer os.Chdir(wd)
+	return job.ReadProject(path)
+}
@@ -0,0 +1,180 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/can1357/gosu/pkg/session"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/goccy/go-yaml"
+	"github.com/samber/lo"
+)
+
+var describeTitleStyle = lipgloss.NewStyle().Bold(true)
+
+type describer struct {
+	strings.Builder
+	indent string
+}
+
+func (d *describer) field(key string, format string, args ...any) {
+	d.WriteString(d.indent)
+	d.WriteString(logDimStyle.Render(fmt.Sprintf("%-14s", key)))
+	fmt.Fprintf(d, format, args...)
+	d.WriteString("\n")
+}
+
+func (d *describer) task(t session.RpcTaskInfo) {
+	d.WriteString(d.indent)
+	d.WriteString(describeTitleStyle.Render(t.Namespace))
+	d.WriteString(" " + t.Status.Icon + " " + t.Status.Code + "\n")
+
+	indent := d.indent
+	d.indent += "  "
+	defer func() { d.indent = indent }()
+
+	if t.Status.Error != "" && t.Status.Error != t.Status.Code {
+		d.field("error", "%s", t.Status.Error)
+	}
+	d.field("restarts", "%d", t.Restarts)
+	if e := t.LastExit; e != nil {
+		d.field("last exit", "%s %s at %s", exitstr(session.RpcExit{Exit: *e}), e.Status.String(), e.Time.Local().Format("2006-01-02 15:04:05"))
+	}
+	if r := t.Report; !r.IsZero() {
+		d.field("pid", "%v", r.Pid)
+		d.field("user", "%s", r.Username)
+		d.field("uptime", "%s", timestr(time.Since(r.CreateTime)))
+		d.field("cpu", "%.2f%%", r.Cpu)
+		d.field("memory", "%s", bytesstr(r.Mem))
+		d.field("threads", "%d", r.Threads)
+		d.field("fds", "%d", r.Fds)
+		d.field("io", "%s read, %s written", bytesstr(float64(r.ReadBytes)), bytesstr(float64(r.WriteBytes)))
+		d.field("ctx switches", "%d voluntary, %d involuntary", r.CtxVoluntary, r.CtxInvoluntary)
+		if len(r.Ports) != 0 {
+			d.field("ports", "%s", strings.Join(r.Ports, ", "))
+		}
+	}
+	children := append([]session.RpcTaskInfo{}, t.Children...)
+	sort.Slice(children, func(i, j int) bool { return children[i].Namespace < children[j].Namespace })
+	for _, child := range children {
+		d.task(child)
+	}
+}
+
+func (d *describer) section(title string) {
+	d.WriteString(d.indent)
+	d.WriteString(describeTitleStyle.Render(title))
+	d.WriteString("\n")
+}
+
+// Removes the null and empty values of a decoded document, keeping the order of the keys.
+func prune(v any) any {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		out := yaml.MapSlice{}
+		for _, item := range v {
+			if value := prune(item.Value); value != nil {
+				out = append(out, yaml.MapItem{Key: item.Key, Value: value})
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		for i := range v {
+			v[i] = prune(v[i])
+		}
+	case string:
+		if v == "" {
+			return nil
+		}
+	}
+	return v
+}
+
+// Writes the value as indented YAML, leaving out the empty fields.
+func (d *describer) yaml(v any) {
+	var doc any
+	js, err := json.Marshal(v)
+	if err == nil {
+		err = yaml.UnmarshalWithOptions(js, &doc, yaml.UseOrderedMap())
+	}
+	if err == nil {
+		js, err = yaml.Marshal(prune(doc))
+	}
+	if err != nil {
+		d.field("error", "%v", err)
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(js), "\n"), "\n") {
+		d.WriteString(d.indent + "  " + line + "\n")
+	}
+}
+
+func (d *describer) job(j session.RpcJobDescription) {
+	if j.Main.Namespace == "" {
+		j.Main.Namespace = j.ID
+	}
+	d.WriteString(describeTitleStyle.Render(j.ID))
+	d.WriteString(" " + j.Main.Status.Icon + " " + j.Main.Status.Code + "\n")
+	d.indent = "  "
+	defer func() { d.indent = "" }()
+
+	if j.Next != nil {
+		d.field("next launch", "%s (in %s)", j.Next.Local().Format("2006-01-02 15:04:05"), nextstr(j.Next))
+	}
+	d.section("manifest")
+	d.yaml(j.Manifest)
+	d.section("options")
+	d.yaml(j.Options)
+
+	d.section("logs")
+	d.indent = "    "
+	for _, f := range j.Logs {
+		rotated := ""
+		if len(f.Rotated) != 0 {
+			rotated = fmt.Sprintf(", %d rotated", len(f.Rotated))
+		}
+		d.field(f.Stream, "%s (%s%s)", f.Path, bytesstr(float64(f.Size)), rotated)
+	}
+
+	if len(j.Whiteboard) != 0 {
+		d.indent = "  "
+		d.section("whiteboard")
+		d.indent = "    "
+		keys := lo.Keys(j.Whiteboard)
+		sort.Strings(keys)
+		for _, k := range keys {
+			d.field(k, "%s", string(j.Whiteboard[k]))
+		}
+	}
+
+	d.indent = "  "
+	d.section("tasks")
+	d.indent = "    "
+	d.task(j.Main)
+}
+
+// Renders the full description of the jobs: manifest, options in effect, logs, whiteboard and
+// the task tree with the process details.
+func RenderDescribe(jobs []session.RpcJobDescription, err error) string {
+	d := &describer{}
+	for i, j := range jobs {
+		if i != 0 {
+			d.WriteString("\n")
+		}
+		d.job(j)
+	}
+	res := d.String()
+	if err != nil {
+		res += errorBoxStyle.Render(err.Error())
+	}
+	return res
+}
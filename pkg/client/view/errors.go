@@ -0,0 +1,65 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/can1357/gosu/pkg/session"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const sampleLines = 12 // Lines of the sample traces shown unless asked for the full trace.
+
+// Renders the error groups followed by a sample trace of each, most recent last.
+func RenderErrors(groups []session.ErrorGroup, full bool, err error) string {
+	columns := []table.Column{
+		{Title: "last seen", Width: 19},
+		{Title: "first seen", Width: 19},
+		{Title: "name", Width: 20},
+		{Title: "count", Width: 6},
+		{Title: "type", Width: 20},
+		{Title: "message", Width: 40},
+	}
+	var rows []table.Row
+	for _, e := range groups {
+		rows = append(rows, table.Row{
+			e.LastSeen.Local().Format("2006-01-02 15:04:05"),
+			e.FirstSeen.Local().Format("2006-01-02 15:04:05"),
+			e.Namespace,
+			fmt.Sprintf("%d", e.Count),
+			e.Type,
+			e.Message,
+		})
+	}
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows(rows),
+		table.WithHeight(len(rows)),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	s.Selected = lipgloss.NewStyle()
+	t.SetStyles(s)
+
+	res := baseStyle.Render(t.View()) + "\n"
+	for _, e := range groups {
+		res += "\n" + logErrorStyle.Render(fmt.Sprintf("%s: %s", e.Type, e.Message))
+		res += logDimStyle.Render(fmt.Sprintf(" (%s, %s, seen %d times)", e.Namespace, e.Fingerprint, e.Count)) + "\n"
+		lines := strings.Split(e.Sample, "\n")
+		if !full && len(lines) > sampleLines {
+			lines = append(lines[:sampleLines], fmt.Sprintf("... %d more lines", len(lines)-sampleLines))
+		}
+		for _, line := range lines {
+			res += logDimStyle.Render("  "+line) + "\n"
+		}
+	}
+	if err != nil {
+		res += errorBoxStyle.Render(err.Error())
+	}
+	return res
+}
@@ -0,0 +1,65 @@
+package view
+
+import (
+	"fmt"
+
+	"github.com/can1357/gosu/pkg/session"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/samber/lo"
+)
+
+func exitstr(e session.RpcExit) string {
+	if e.Signal != "" {
+		return "SIG" + e.Signal
+	} else if e.Code >= 0 {
+		return fmt.Sprintf("%d", e.Code)
+	}
+	return "-"
+}
+
+// Renders the crash history, most recent last.
+func RenderHistory(exits []session.RpcExit, err error) string {
+	columns := []table.Column{
+		{Title: "time", Width: 19},
+		{Title: "name", Width: 25},
+		{Title: "exit", Width: 8},
+		{Title: "status", Width: 13},
+		{Title: "↺", Width: 3},
+		{Title: "error", Width: 40},
+	}
+	var rows []table.Row
+	for _, e := range exits {
+		ns := e.Namespace
+		if ns == "" {
+			ns = e.Job
+		}
+		rows = append(rows, table.Row{
+			e.Time.Local().Format("2006-01-02 15:04:05"),
+			ns,
+			exitstr(e),
+			e.Status.Icon() + " " + e.Status.String(),
+			lo.Ternary(e.Restarted, "✔", ""),
+			e.Error,
+		})
+	}
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows(rows),
+		table.WithHeight(len(rows)),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	s.Selected = lipgloss.NewStyle()
+	t.SetStyles(s)
+
+	res := baseStyle.Render(t.View()) + "\n"
+	if err != nil {
+		res += errorBoxStyle.Render(err.Error())
+	}
+	return res
+}
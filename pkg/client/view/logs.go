@@ -0,0 +1,82 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/can1357/gosu/pkg/clog"
+	"github.com/can1357/gosu/pkg/session"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var logPalette = []lipgloss.Color{"39", "208", "170", "42", "214", "81", "203", "141", "117", "220"}
+var logDimStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+var logErrorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+var logWarnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+
+// Renders streamed log lines with a coloured prefix per job.
+type LogPrinter struct {
+	width int // Width of the widest prefix seen so far.
+}
+
+func jobColor(job string) lipgloss.Color {
+	h := fnv.New32a()
+	h.Write([]byte(job))
+	return logPalette[h.Sum32()%uint32(len(logPalette))]
+}
+
+func (p *LogPrinter) Render(msg session.RpcLogMessage) string {
+	e := msg.Entry
+	if e == nil {
+		return strings.TrimRight(msg.Line, "\r\n") + "\n"
+	}
+	ns := e.Namespace
+	if ns == "" {
+		ns = e.Job
+	}
+	p.width = max(p.width, len(ns))
+
+	b := strings.Builder{}
+	if !e.Time.IsZero() {
+		b.WriteString(logDimStyle.Render(e.Time.Local().Format("15:04:05.000")))
+		b.WriteString(" ")
+	}
+	b.WriteString(lipgloss.NewStyle().Foreground(jobColor(e.Job)).Render(fmt.Sprintf("%-*s |", p.width, ns)))
+	b.WriteString(" ")
+
+	style := lipgloss.NewStyle()
+	switch e.Level {
+	case "error", "fatal", "panic":
+		style = logErrorStyle
+	case "warn", "warning":
+		style = logWarnStyle
+	case "":
+		if e.Stream == clog.StreamStderr {
+			style = logErrorStyle
+		}
+	}
+	if e.Level != "" {
+		b.WriteString(style.Render(strings.ToUpper(e.Level)))
+		b.WriteString(" ")
+	}
+	b.WriteString(style.Render(strings.TrimRight(e.Message, "\r\n")))
+
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := e.Fields[k].(string)
+		if !ok {
+			js, _ := json.Marshal(e.Fields[k])
+			v = string(js)
+		}
+		b.WriteString(logDimStyle.Render(fmt.Sprintf(" %s=%s", k, v)))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
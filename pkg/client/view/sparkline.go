@@ -0,0 +1,42 @@
+package view
+
+import (
+	"strings"
+)
+
+var sparkRunes = []rune("▁▂▃▄▅▆▇█")
+
+// Averages the values into at most width buckets.
+func resample(values []float64, width int) []float64 {
+	if len(values) <= width {
+		return values
+	}
+	out := make([]float64, width)
+	for i := range out {
+		lo, hi := i*len(values)/width, (i+1)*len(values)/width
+		for _, v := range values[lo:hi] {
+			out[i] += v
+		}
+		out[i] /= float64(hi - lo)
+	}
+	return out
+}
+
+// Renders the values as a sparkline of the given width, right aligned and scaled to the maximum.
+func Sparkline(values []float64, width int) string {
+	values = resample(values, width)
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	b := strings.Builder{}
+	b.WriteString(strings.Repeat(" ", width-len(values)))
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = min(int(v/peak*float64(len(sparkRunes)-1)+0.5), len(sparkRunes)-1)
+		}
+		b.WriteRune(sparkRunes[i])
+	}
+	return b.String()
+}
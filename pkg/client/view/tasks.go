@@ -2,13 +2,17 @@ package view
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/can1357/gosu/pkg/session"
+	"github.com/can1357/gosu/pkg/task"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/samber/lo"
 )
 
 var baseStyle = lipgloss.NewStyle().
@@ -41,7 +45,29 @@ func timestr(d time.Duration) string {
 	}
 }
 
-func displayRpcTaskStateRecursive(out *[]table.Row, task session.RpcTaskInfo, prefix string) {
+func nextstr(next *time.Time) string {
+	if next == nil {
+		return ""
+	}
+	return timestr(time.Until(*next))
+}
+
+// Cells of the wide listing.
+func wideCells(r *task.Report) table.Row {
+	if r.IsZero() {
+		return table.Row{"", "", "", "", "", ""}
+	}
+	return table.Row{
+		fmt.Sprintf("%d", r.Threads),
+		fmt.Sprintf("%d", r.Fds),
+		bytesstr(float64(r.ReadBytes)),
+		bytesstr(float64(r.WriteBytes)),
+		fmt.Sprintf("%d/%d", r.CtxVoluntary, r.CtxInvoluntary),
+		strings.Join(r.Ports, ","),
+	}
+}
+
+func displayRpcTaskStateRecursive(out *[]table.Row, task session.RpcTaskInfo, prefix string, next string, wide bool) {
 	uid := task.Namespace
 
 	var entry table.Row
@@ -50,11 +76,12 @@ func displayRpcTaskStateRecursive(out *[]table.Row, task session.RpcTaskInfo, pr
 			prefix + uid,
 			"🧊",
 			"",
-			"0", //fmt.Sprintf("%v", task.Restarts), // TODO
+			fmt.Sprintf("%v", task.Restarts),
 			task.Status.Icon + " " + task.Status.Code,
 			"",
 			"",
 			"",
+			next,
 		}
 	} else {
 		process := &task.Report
@@ -62,32 +89,36 @@ func displayRpcTaskStateRecursive(out *[]table.Row, task session.RpcTaskInfo, pr
 			prefix + uid,
 			fmt.Sprintf("%v", process.Pid),
 			timestr(time.Since(task.Report.CreateTime)),
-			"0", //fmt.Sprintf("%v", task.Restarts), // TODO
+			fmt.Sprintf("%v", task.Restarts),
 			task.Status.Icon + " " + task.Status.Code,
 			fmt.Sprintf("%.2f%%", process.Cpu),
 			fmt.Sprintf("%v", bytesstr(process.Mem)),
 			process.Username,
+			next,
 		}
 	}
 
+	if wide {
+		entry = append(entry, wideCells(&task.Report)...)
+	}
 	if len(task.Children) == 0 {
 		*out = append(*out, entry)
 	} else {
 		for idx, child := range task.Children {
 			if idx == len(task.Children)-1 {
-				displayRpcTaskStateRecursive(out, child, "") //prefix+"└─ ")
+				displayRpcTaskStateRecursive(out, child, "", next, wide) //prefix+"└─ ")
 			} else {
-				displayRpcTaskStateRecursive(out, child, "") //prefix+"├─ ")
+				displayRpcTaskStateRecursive(out, child, "", next, wide) //prefix+"├─ ")
 			}
 		}
 	}
 }
-func torows(jobs []session.RpcJobInfo) (rows []table.Row) {
+func torows(jobs []session.RpcJobInfo, wide bool) (rows []table.Row) {
 	for _, job := range jobs {
 		if job.Main.Namespace == "" {
 			job.Main.Namespace = job.ID
 		}
-		displayRpcTaskStateRecursive(&rows, job.Main, "")
+		displayRpcTaskStateRecursive(&rows, job.Main, "", nextstr(job.Next), wide)
 	}
 	sort.SliceStable(rows, func(i, j int) bool {
 		if rows[i][4] != rows[j][4] {
@@ -99,6 +130,38 @@ func torows(jobs []session.RpcJobInfo) (rows []table.Row) {
 	return
 }
 
+var taskColumns = []table.Column{
+	{Title: "name", Width: 25},
+	{Title: "pid", Width: 8},
+	{Title: "uptime", Width: 10},
+	{Title: "↺", Width: 3},
+	{Title: "status", Width: 13},
+	{Title: "cpu", Width: 8},
+	{Title: "mem", Width: 8},
+	{Title: "user", Width: 8},
+	{Title: "next", Width: 10},
+}
+var wideColumns = []table.Column{
+	{Title: "threads", Width: 7},
+	{Title: "fds", Width: 6},
+	{Title: "read", Width: 10},
+	{Title: "write", Width: 10},
+	{Title: "ctx switches", Width: 14},
+	{Title: "ports", Width: 24},
+}
+var trendColumns = []table.Column{
+	{Title: "cpu trend", Width: trendWidth},
+	{Title: "mem trend", Width: trendWidth},
+}
+
+const (
+	trendWidth  = 20 // Width of the sparklines in the table, one sample per second.
+	detailWidth = 48 // Width of the sparklines in the detail pane.
+)
+
+// Fetches the resource history of the jobs matching the pattern.
+type MetricsFetcher func(match string, since time.Time) ([]session.RpcTaskMetrics, error)
+
 type Tasklist struct {
 	table      table.Model
 	fetch      func() ([]session.RpcJobInfo, error)
@@ -106,6 +169,12 @@ type Tasklist struct {
 	fetchError error
 	rows       []table.Row
 	lastFetch  time.Time
+	wide       bool // Whether the extended process columns are shown.
+	metrics    MetricsFetcher
+	trends     map[string][]task.MetricSample // Recent samples by namespace.
+	detail     bool                           // Whether the detail pane of the selected task is open.
+	recent     []task.MetricSample            // Samples of the selected task for the detail pane.
+	history    []task.MetricSample            //
 }
 
 type tickMsg time.Time
@@ -118,10 +187,78 @@ func (m *Tasklist) doTick() tea.Cmd {
 		return tickMsg(t)
 	})
 }
+
+// Enables the sparklines of the resource usage and the detail pane.
+func (m *Tasklist) WithMetrics(fetch MetricsFetcher) *Tasklist {
+	m.metrics = fetch
+	m.refresh()
+	return m
+}
+
+// Enables the extended process columns.
+func (m *Tasklist) Wide() *Tasklist {
+	m.wide = true
+	m.refresh()
+	return m
+}
+
+func (m *Tasklist) refresh() {
+	columns := append([]table.Column{}, taskColumns...)
+	if m.wide {
+		columns = append(columns, wideColumns...)
+	}
+	if m.metrics != nil {
+		columns = append(columns, trendColumns...)
+	}
+	m.table.SetRows(nil)
+	m.table.SetColumns(columns)
+	m.lastFetch = time.Time{}
+	m.updateData()
+}
+
+func findSamples(list []session.RpcTaskMetrics, ns string) []task.MetricSample {
+	for _, t := range list {
+		if t.Namespace == ns {
+			return t.Samples
+		}
+	}
+	return nil
+}
+func (m *Tasklist) fetchMetrics() {
+	res, _ := m.metrics("", time.Now().Add(-trendWidth*time.Second))
+	m.trends = map[string][]task.MetricSample{}
+	for _, t := range res {
+		m.trends[t.Namespace] = t.Samples
+	}
+
+	m.recent, m.history = nil, nil
+	if row := m.table.SelectedRow(); m.detail && len(row) != 0 {
+		job, _, _ := strings.Cut(row[0], "/")
+		match := "^" + regexp.QuoteMeta(job) + "$"
+		if res, err := m.metrics(match, time.Now().Add(-5*time.Minute)); err == nil {
+			m.recent = findSamples(res, row[0])
+		}
+		if res, err := m.metrics(match, time.Time{}); err == nil {
+			m.history = findSamples(res, row[0])
+		}
+	}
+}
+
+func series(samples []task.MetricSample, get func(task.MetricSample) float64) []float64 {
+	out := make([]float64, len(samples))
+	for i, s := range samples {
+		out[i] = get(s)
+	}
+	return out
+}
+
 func (m *Tasklist) updateData() {
 	if time.Since(m.lastFetch) > 1*time.Second {
 		m.lastFetch = time.Now()
 		m.jobs, m.fetchError = m.fetch()
+		if m.metrics != nil && m.fetchError == nil {
+			m.fetchMetrics()
+		}
 	}
 	if m.fetchError != nil {
 		m.rows = []table.Row{
@@ -133,7 +270,16 @@ func (m *Tasklist) updateData() {
 			},
 		}
 	} else {
-		m.rows = torows(m.jobs)
+		m.rows = torows(m.jobs, m.wide)
+		if m.metrics != nil {
+			for i, row := range m.rows {
+				samples := m.trends[row[0]]
+				m.rows[i] = append(row,
+					Sparkline(series(samples, func(s task.MetricSample) float64 { return s.Cpu }), trendWidth),
+					Sparkline(series(samples, func(s task.MetricSample) float64 { return s.Mem }), trendWidth),
+				)
+			}
+		}
 	}
 	m.table.SetRows(m.rows)
 	m.table.SetHeight(len(m.rows))
@@ -150,6 +296,10 @@ func (m *Tasklist) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else {
 				m.table.Focus()
 			}
+		case "enter":
+			m.detail = !m.detail
+			m.lastFetch = time.Time{}
+			m.updateData()
 		case "q", "ctrl+c":
 			return m, tea.Quit
 		}
@@ -173,6 +323,39 @@ var errorBoxStyle = lipgloss.NewStyle().
 	Padding(0, 2).
 	Width(96)
 
+var detailMetrics = []struct {
+	name   string
+	get    func(task.MetricSample) float64
+	format func(float64) string
+}{
+	{"cpu", func(s task.MetricSample) float64 { return s.Cpu }, func(v float64) string { return fmt.Sprintf("%.2f%%", v) }},
+	{"mem", func(s task.MetricSample) float64 { return s.Mem }, bytesstr},
+	{"fds", func(s task.MetricSample) float64 { return s.Fds }, func(v float64) string { return fmt.Sprintf("%.0f", v) }},
+	{"threads", func(s task.MetricSample) float64 { return s.Threads }, func(v float64) string { return fmt.Sprintf("%.0f", v) }},
+}
+
+// Renders the resource history of the selected task over the last 5 minutes and 24 hours.
+func (m *Tasklist) detailView() string {
+	row := m.table.SelectedRow()
+	if len(row) == 0 {
+		return ""
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%-8s %-*s %-10s %-*s %s\n", row[0], detailWidth, "last 5m", "now", detailWidth, "last 24h", "peak")
+	for _, metric := range detailMetrics {
+		recent, history := series(m.recent, metric.get), series(m.history, metric.get)
+		now, peak := "-", "-"
+		if len(recent) != 0 {
+			now = metric.format(recent[len(recent)-1])
+		}
+		if len(history) != 0 {
+			peak = metric.format(lo.Max(history))
+		}
+		fmt.Fprintf(&b, "%-8s %s %-10s %s %s\n", metric.name, Sparkline(recent, detailWidth), now, Sparkline(history, detailWidth), peak)
+	}
+	return baseStyle.Render(strings.TrimRight(b.String(), "\n"))
+}
+
 func (m *Tasklist) View() string {
 	res := baseStyle.Render(m.table.View())
 	if m.fetchError != nil {
@@ -181,24 +364,16 @@ func (m *Tasklist) View() string {
 			Render(m.fetchError.Error())
 	} else {
 		res += "\n"
+		if m.detail && m.metrics != nil {
+			res += m.detailView() + "\n"
+		}
 	}
 	return res
 }
 
 func NewTasklist(fetch func() ([]session.RpcJobInfo, error)) *Tasklist {
-	columns := []table.Column{
-		{Title: "name", Width: 25},
-		{Title: "pid", Width: 8},
-		{Title: "uptime", Width: 10},
-		{Title: "↺", Width: 3},
-		{Title: "status", Width: 13},
-		{Title: "cpu", Width: 8},
-		{Title: "mem", Width: 8},
-		{Title: "user", Width: 8},
-	}
-
 	t := table.New(
-		table.WithColumns(columns),
+		table.WithColumns(taskColumns),
 		table.WithFocused(true),
 	)
 
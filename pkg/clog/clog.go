@@ -7,16 +7,32 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	"github.com/can1357/gosu/pkg/util"
 )
 
 type loggerKey struct{}
 
 type Options struct {
-	Output   string `json:"stdout,omitempty"`    // The file to which to redirect stdout, "null" to discard.
-	Error    string `json:"stderr,omitempty"`    // The file to which to redirect stderr, "null" to discard, "merge" to redirect to stdout.
-	LogTime  string `json:"log_time,omitempty"`  // The format to use for timestamps.
-	LogName  string `json:"log_name,omitempty"`  // The name to prepend to log lines.
-	PfxWidth int    `json:"pfx_width,omitempty"` // The width of the prefix.
+	Output   string `json:"stdout,omitempty"`     // The file to which to redirect stdout, "null" to discard.
+	Error    string `json:"stderr,omitempty"`     // The file to which to redirect stderr, "null" to discard, "merge" to redirect to stdout.
+	LogTime  string `json:"log_time,omitempty"`   // The format to use for timestamps.
+	LogName  string `json:"log_name,omitempty"`   // The name to prepend to log lines.
+	PfxWidth int    `json:"pfx_width,omitempty"`  // The width of the prefix.
+	Format   string `json:"log_format,omitempty"` // The format of the lines written, "text" or "json".
+	Parse    bool   `json:"log_parse,omitempty"`  // Parses output that is already JSON to keep its level and fields.
+
+	// Grouping of multi-line records such as stack traces.
+	Multiline        string                `json:"log_multiline,omitempty"`         // "indent" to group indented lines, or a pattern matching the first line of a record.
+	MultilineTimeout util.ParsableDuration `json:"log_multiline_timeout,omitempty"` // The time to wait for a continuation line.
+
+	// Rotation policy of the log files.
+	MaxSize  util.ParsableSize `json:"log_max_size,omitempty"`  // The size after which the file is rotated.
+	Daily    bool              `json:"log_daily,omitempty"`     // Rotates the file every day.
+	MaxFiles int               `json:"log_max_files,omitempty"` // The number of rotated files to keep, 0 keeps all of them.
+	Compress bool              `json:"log_compress,omitempty"`  // Compresses the rotated files with gzip.
+
+	Sinks []SinkOptions `json:"log_sinks,omitempty"` // External sinks the lines are shipped to.
 }
 
 type Stream uint8
@@ -37,15 +53,42 @@ func (str Stream) String() string {
 		return fmt.Sprintf("stream(%d)", str)
 	}
 }
+func (str Stream) MarshalText() ([]byte, error) {
+	return []byte(str.String()), nil
+}
+func (str *Stream) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "stdout":
+		*str = StreamStdout
+	case "stderr":
+		*str = StreamStderr
+	default:
+		return fmt.Errorf("invalid stream: %s", text)
+	}
+	return nil
+}
+
+// The underlying file of a stream.
+type Handle interface {
+	io.Writer
+	Sync() error
+	Close() error
+}
 
 type Logger struct {
-	Handles        [StreamMax]*os.File
+	Handles        [StreamMax]Handle
 	Paths          [StreamMax]string
 	Opened         Stream
 	Timestamp      string
 	Namespace      string
 	PfxWidth       int
+	Format         string
+	Parse          bool
 	prefixComputed string
+	group          *lineGroup // Grouping of multi-line records, nil if disabled.
+	grouped        *sync.Map  // Writers holding a grouped record, flushed on close.
+	sinks          []Hook     // Hooks only receiving the lines of this logger and its forks.
+	ownSinks       []*Sink    // Sinks opened by this logger.
 
 	// Cached formatters.
 	mu  sync.RWMutex
@@ -59,6 +102,11 @@ func (logger *Logger) Fork(ns string) (r *Logger) {
 		Timestamp: logger.Timestamp,
 		Namespace: logger.Namespace,
 		PfxWidth:  logger.PfxWidth,
+		Format:    logger.Format,
+		Parse:     logger.Parse,
+		group:     logger.group,
+		grouped:   logger.grouped,
+		sinks:     logger.sinks,
 	}
 
 	if ns != "" {
@@ -87,6 +135,12 @@ func (logger *Logger) Fork(ns string) (r *Logger) {
 	return
 }
 
+// Returns the job the logger belongs to, the first component of the namespace.
+func (logger *Logger) Job() string {
+	job, _, _ := strings.Cut(logger.Namespace, "/")
+	return job
+}
+
 func (logger *Logger) getFormatter(str Stream) io.Writer {
 	if logger.fmt[str] != nil {
 		return logger.fmt[str]
@@ -111,6 +165,12 @@ func (logger *Logger) Close() {
 	defer logger.mu.Unlock()
 
 	mask := logger.Opened
+	if logger.grouped != nil {
+		logger.grouped.Range(func(key, value any) bool {
+			key.(*Writer).Flush()
+			return true
+		})
+	}
 	for i, handle := range logger.Handles {
 		if fmt := logger.fmt[i]; fmt != nil {
 			fmt.Flush()
@@ -122,6 +182,10 @@ func (logger *Logger) Close() {
 		mask >>= 1
 	}
 	logger.Opened = 0
+	for _, sink := range logger.ownSinks {
+		sink.Close()
+	}
+	logger.ownSinks = nil
 }
 
 func (logger *Logger) Flush() {
@@ -143,8 +207,24 @@ func (logger *Logger) Sync() {
 	}
 }
 
+// Rotates the log files regardless of the policy.
+func (logger *Logger) Rotate() (err error) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	seen := map[*File]bool{}
+	for _, handle := range logger.Handles {
+		if f, ok := handle.(*File); ok && !seen[f] {
+			seen[f] = true
+			if e := f.Rotate(); e != nil {
+				err = e
+			}
+		}
+	}
+	return
+}
+
 var defaultLogger = Logger{
-	Handles: [StreamMax]*os.File{
+	Handles: [StreamMax]Handle{
 		StreamStdout: os.Stdout,
 		StreamStderr: os.Stderr,
 	},
@@ -156,6 +236,13 @@ func FromContext(ctx context.Context) *Logger {
 	}
 	return &defaultLogger
 }
+func openHandle(path string, opts Options) (Handle, error) {
+	f, err := OpenFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
 func New(prev *Logger, opts Options) (r *Logger, err error) {
 	if prev == nil {
 		prev = &defaultLogger
@@ -174,7 +261,7 @@ func New(prev *Logger, opts Options) (r *Logger, err error) {
 			r.Handles[StreamStdout] = nil
 		} else {
 			r.Paths[StreamStdout] = opts.Output
-			r.Handles[StreamStdout], err = os.OpenFile(opts.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			r.Handles[StreamStdout], err = openHandle(opts.Output, opts)
 			if err != nil {
 				r.Close()
 				return
@@ -189,7 +276,7 @@ func New(prev *Logger, opts Options) (r *Logger, err error) {
 			r.Handles[StreamStderr] = r.Handles[StreamStdout]
 		} else {
 			r.Paths[StreamStderr] = opts.Error
-			r.Handles[StreamStderr], err = os.OpenFile(opts.Error, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			r.Handles[StreamStderr], err = openHandle(opts.Error, opts)
 			if err != nil {
 				r.Close()
 				return
@@ -203,6 +290,33 @@ func New(prev *Logger, opts Options) (r *Logger, err error) {
 	if opts.PfxWidth != 0 {
 		r.PfxWidth = opts.PfxWidth
 	}
+	switch opts.Format {
+	case "":
+	case FormatText, FormatJson:
+		r.Format = opts.Format
+	default:
+		r.Close()
+		return nil, fmt.Errorf("invalid log format: %s", opts.Format)
+	}
+	if opts.Parse {
+		r.Parse = true
+	}
+	if opts.Multiline != "" {
+		if r.group, err = newLineGroup(opts.Multiline, opts.MultilineTimeout.Duration); err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.grouped = &sync.Map{}
+	}
+	for _, o := range opts.Sinks {
+		sink, e := NewSink(o)
+		if e != nil {
+			r.Close()
+			return nil, e
+		}
+		r.sinks = append(r.sinks[:len(r.sinks):len(r.sinks)], sink)
+		r.ownSinks = append(r.ownSinks, sink)
+	}
 	return
 }
 func (logger *Logger) WithContext(ctx context.Context) context.Context {
@@ -0,0 +1,91 @@
+package clog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJson = "json"
+)
+
+// Structured form of a log line.
+type Entry struct {
+	Time      time.Time      `json:"time"`
+	Job       string         `json:"job,omitempty"`
+	Namespace string         `json:"namespace,omitempty"`
+	Stream    Stream         `json:"stream"`
+	Level     string         `json:"level,omitempty"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields,omitempty"` // Extra fields of the line if it was written as JSON.
+}
+
+// Parses a line written in the JSON format, returns false if it is not one.
+func ParseEntry(line string) (e Entry, ok bool) {
+	if !strings.HasPrefix(line, "{") {
+		return
+	}
+	ok = json.Unmarshal([]byte(line), &e) == nil && !e.Time.IsZero()
+	return
+}
+
+// Pino style numeric levels.
+var numericLevels = map[int]string{
+	10: "trace",
+	20: "debug",
+	30: "info",
+	40: "warn",
+	50: "error",
+	60: "fatal",
+}
+
+// Lifts the message, level and time out of a line that is itself JSON such as the ones
+// written by pino or zap, the rest of the object is kept as fields.
+func (e *Entry) parseMessage() {
+	msg := strings.TrimSpace(e.Message)
+	if !strings.HasPrefix(msg, "{") || !strings.HasSuffix(msg, "}") {
+		return
+	}
+	var fields map[string]any
+	if json.Unmarshal([]byte(msg), &fields) != nil {
+		return
+	}
+	for _, key := range []string{"msg", "message"} {
+		if v, ok := fields[key].(string); ok {
+			e.Message = v
+			delete(fields, key)
+			break
+		}
+	}
+	switch v := fields["level"].(type) {
+	case string:
+		e.Level = strings.ToLower(v)
+		delete(fields, "level")
+	case float64:
+		if lvl, ok := numericLevels[int(v)]; ok {
+			e.Level = lvl
+			delete(fields, "level")
+		}
+	}
+	switch v := fields["time"].(type) {
+	case float64: // Milliseconds since epoch.
+		e.Time = time.UnixMilli(int64(v))
+		delete(fields, "time")
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			e.Time = t
+			delete(fields, "time")
+		}
+	}
+	if v, ok := fields["ts"].(float64); ok { // Seconds since epoch.
+		e.Time = time.UnixMicro(int64(v * 1e6))
+		delete(fields, "ts")
+	}
+	if len(fields) != 0 {
+		e.Fields = fields
+	} else {
+		e.Fields = nil
+	}
+}
@@ -0,0 +1,40 @@
+package clog
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const maxGroupLines = 1000 // Records are cut after this many lines.
+
+// Groups continuation lines such as the frames of a stack trace into a single record.
+type lineGroup struct {
+	start   *regexp.Regexp // Matches the first line of a record, nil groups indented lines instead.
+	timeout time.Duration  // The time to wait for a continuation before flushing the record.
+}
+
+func newLineGroup(mode string, timeout time.Duration) (*lineGroup, error) {
+	g := &lineGroup{timeout: timeout}
+	if g.timeout <= 0 {
+		g.timeout = 100 * time.Millisecond
+	}
+	if mode != "indent" {
+		rgx, err := regexp.Compile(mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline pattern: %w", err)
+		}
+		g.start = rgx
+	}
+	return g, nil
+}
+
+var causedBy = []byte("Caused by:")
+
+func (g *lineGroup) continues(line []byte) bool {
+	if g.start != nil {
+		return !g.start.Match(line)
+	}
+	return len(line) != 0 && (line[0] == ' ' || line[0] == '\t') || bytes.HasPrefix(line, causedBy)
+}
@@ -0,0 +1,151 @@
+package clog
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordHook struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (h *recordHook) Write(line string, entry *Entry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, entry.Message)
+}
+func (h *recordHook) records() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.messages...)
+}
+
+func newGroupWriter(t *testing.T, mode string, timeout time.Duration) (*Writer, *recordHook) {
+	g, err := newLineGroup(mode, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := &recordHook{}
+	logger := &Logger{group: g, grouped: &sync.Map{}, sinks: []Hook{hook}}
+	return createWriter(logger, nil, StreamStderr), hook
+}
+
+func TestLineGroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		writes []string
+		want   []string
+	}{
+		{
+			name:   "single lines",
+			mode:   "indent",
+			writes: []string{"a\nb\n"},
+			want:   []string{"a", "b"},
+		},
+		{
+			name: "node stack trace",
+			mode: "indent",
+			writes: []string{
+				"TypeError: x is undefined\n",
+				"    at f (/app/a.js:1:1)\n",
+				"    at g (/app/a.js:2:1)\n",
+				"listening\n",
+			},
+			want: []string{
+				"TypeError: x is undefined\n    at f (/app/a.js:1:1)\n    at g (/app/a.js:2:1)",
+				"listening",
+			},
+		},
+		{
+			name: "java caused by",
+			mode: "indent",
+			writes: []string{
+				"java.lang.IllegalStateException: boom\n\tat A.run(A.java:1)\n",
+				"Caused by: java.io.IOException: closed\n\tat B.read(B.java:2)\n",
+			},
+			want: []string{
+				"java.lang.IllegalStateException: boom\n\tat A.run(A.java:1)\nCaused by: java.io.IOException: closed\n\tat B.read(B.java:2)",
+			},
+		},
+		{
+			name:   "split writes",
+			mode:   "indent",
+			writes: []string{"Error: bo", "om\n  at", " x\n", "done\n"},
+			want:   []string{"Error: boom\n  at x", "done"},
+		},
+		{
+			name:   "empty line starts a record",
+			mode:   "indent",
+			writes: []string{"Error\n  at x\n\n  at y\n"},
+			want:   []string{"Error\n  at x", "\n  at y"},
+		},
+		{
+			name: "start pattern",
+			mode: `^\[\d+\]`,
+			writes: []string{
+				"[1] first\n",
+				"continued\n",
+				"  indented\n",
+				"[2] second\n",
+			},
+			want: []string{"[1] first\ncontinued\n  indented", "[2] second"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, hook := newGroupWriter(t, tt.mode, time.Hour)
+			for _, s := range tt.writes {
+				if _, err := w.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w.Flush()
+			if got := hook.records(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineGroupLimit(t *testing.T) {
+	w, hook := newGroupWriter(t, "indent", time.Hour)
+	w.Write([]byte("Error\n" + strings.Repeat("  at x\n", maxGroupLines)))
+	w.Flush()
+	got := hook.records()
+	if len(got) != 2 || strings.Count(got[0], "\n") != maxGroupLines-1 {
+		t.Errorf("got %d records, want the first one cut at %d lines", len(got), maxGroupLines)
+	}
+}
+
+func TestLineGroupTimeout(t *testing.T) {
+	w, hook := newGroupWriter(t, "indent", 10*time.Millisecond)
+	w.Write([]byte("Error\n  at x\n"))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(hook.records()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, want := hook.records(), []string{"Error\n  at x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+
+	// A partial line is not flushed until it is complete.
+	w.Write([]byte("Err"))
+	time.Sleep(50 * time.Millisecond)
+	if got := hook.records(); len(got) != 1 {
+		t.Errorf("a partial line was flushed: %q", got)
+	}
+}
+
+func TestNewLineGroup(t *testing.T) {
+	if _, err := newLineGroup("(", 0); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	if g, err := newLineGroup("indent", 0); err != nil || g.timeout <= 0 {
+		t.Errorf("newLineGroup(indent) = %v, %v, want a default timeout", g, err)
+	}
+}
@@ -1,13 +1,17 @@
 package clog
 
 import (
+	"bufio"
+	"compress/gzip"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/icza/backscanner"
 )
 
-func tailFileN(file *os.File, numLines int) (out []string, err error) {
+func tailFileN(file *os.File, numLines int, keep func(string) bool) (out []string, err error) {
 	// Seek to the end.
 	fileSize, err := file.Seek(0, 2)
 	if err != nil {
@@ -24,26 +28,115 @@ func tailFileN(file *os.File, numLines int) (out []string, err error) {
 			}
 			return nil, err
 		}
+		if keep != nil && !keep(line) {
+			continue
+		}
 		out[at] = line
 		at--
 	}
 	return out[at+1:], nil
 }
-func tailPathN(path string, numLines int) (out []string, err error) {
+func tailPathN(path string, numLines int, keep func(string) bool) (out []string, err error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	return tailFileN(file, numLines)
+	return tailFileN(file, numLines, keep)
+}
+
+func tailGzipN(path string, numLines int, keep func(string) bool) (out []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if keep != nil && !keep(scanner.Text()) {
+			continue
+		}
+		out = append(out, scanner.Text())
+		if len(out) > 2*numLines {
+			out = append(out[:0], out[len(out)-numLines:]...)
+		}
+	}
+	if len(out) > numLines {
+		out = out[len(out)-numLines:]
+	}
+	return out, scanner.Err()
 }
 
 func (logger *Logger) Tail(numLines int, stream Stream) (out []string, err error) {
+	return logger.TailFunc(numLines, stream, nil)
+}
+
+// Returns the last lines of the stream for which keep returns true, reading through the rotated files if needed.
+func (logger *Logger) TailFunc(numLines int, stream Stream, keep func(string) bool) (out []string, err error) {
 	logger.mu.RLock()
 	path := logger.Paths[stream]
 	logger.mu.RUnlock()
 	if path == "" {
 		return nil, nil
 	}
-	return tailPathN(path, numLines)
+	if out, err = tailPathN(path, numLines, keep); err != nil || len(out) >= numLines {
+		return
+	}
+
+	// Continue with the rotated files, newest first.
+	rotated := RotatedFiles(path)
+	for i := len(rotated) - 1; i >= 0 && len(out) < numLines; i-- {
+		var prev []string
+		if strings.HasSuffix(rotated[i], ".gz") {
+			prev, err = tailGzipN(rotated[i], numLines-len(out), keep)
+		} else if prev, err = tailPathN(rotated[i], numLines-len(out)+1, keep); len(prev) != 0 && prev[len(prev)-1] == "" {
+			prev = prev[:len(prev)-1]
+		}
+		if err != nil {
+			// The file may have been compressed or pruned in the meantime.
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			return
+		}
+		if need := numLines - len(out); len(prev) > need {
+			prev = prev[len(prev)-need:]
+		}
+		out = append(prev, out...)
+	}
+	return
+}
+
+// Recovers the structured form of a line read back from the log files.
+func (logger *Logger) ParseLine(line string, stream Stream) (e Entry) {
+	if logger.Format == FormatJson {
+		if e, ok := ParseEntry(line); ok {
+			return e
+		}
+	}
+	e = Entry{Job: logger.Job(), Namespace: logger.Namespace, Stream: stream, Message: line}
+	if layout := logger.Timestamp; layout != "" {
+		if n := len(time.Now().Format(layout)); len(line) >= n {
+			if t, err := time.ParseInLocation(layout, line[:n], time.Local); err == nil {
+				e.Time = t
+				e.Message = line[n:]
+			}
+		}
+	}
+	if ns, msg, ok := strings.Cut(e.Message, " | "); ok {
+		if ns = strings.TrimSpace(ns); ns != "" && !strings.Contains(ns, " ") {
+			e.Namespace = ns
+			e.Message = msg
+		}
+	}
+	if logger.Parse {
+		e.parseMessage()
+	}
+	return
 }
@@ -0,0 +1,220 @@
+package clog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotateStamp = "20060102-150405.000"
+
+// A log file that is rotated according to the options, rotation only ever happens between
+// two writes so that lines are never split across files.
+type File struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64
+	day    time.Time // The day the current file was opened.
+	policy Options
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func OpenFile(path string, policy Options) (f *File, err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f = &File{path: path, policy: policy}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+	return
+}
+func (f *File) open() (err error) {
+	f.file, err = os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	f.size = 0
+	f.day = startOfDay(time.Now())
+	if fi, err := f.file.Stat(); err == nil {
+		f.size = fi.Size()
+		if !fi.ModTime().IsZero() && f.size != 0 {
+			f.day = startOfDay(fi.ModTime())
+		}
+	}
+	return
+}
+
+func (f *File) shouldRotate(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if max := f.policy.MaxSize; max.IsPositive() && f.size+int64(n) > int64(max.Value) {
+		return true
+	}
+	return f.policy.Daily && !startOfDay(time.Now()).Equal(f.day)
+}
+
+func (f *File) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(len(p)) {
+		if err := f.rotateLocked(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rotate %s: %v\n", f.path, err)
+		}
+	}
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return
+}
+func (f *File) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.file.Sync()
+}
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Rotates the file regardless of the policy.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotateLocked()
+}
+func (f *File) rotateLocked() error {
+	if f.size == 0 {
+		return nil
+	}
+	f.file.Close()
+	f.file = nil
+
+	// Move the current file aside, then reopen a fresh one before doing anything slow.
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	now := time.Now()
+	var rotated string
+	for {
+		rotated = base + "." + now.Format(rotateStamp) + ext
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			if _, err := os.Stat(rotated + ".gz"); os.IsNotExist(err) {
+				break
+			}
+		}
+		now = now.Add(time.Millisecond)
+	}
+	renameErr := os.Rename(f.path, rotated)
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	policy := f.policy
+	path := f.path
+	go func() {
+		if policy.Compress {
+			if err := compressFile(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to compress %s: %v\n", rotated, err)
+			}
+		}
+		if policy.MaxFiles > 0 {
+			files := RotatedFiles(path)
+			for len(files) > policy.MaxFiles {
+				os.Remove(files[0])
+				files = files[1:]
+			}
+		}
+	}()
+	return nil
+}
+
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz.tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz.tmp")
+		return
+	}
+	if err = os.Rename(path+".gz.tmp", path+".gz"); err == nil {
+		os.Remove(path)
+	}
+	return
+}
+
+// Returns the rotated files of the log at the given path, oldest first.
+func RotatedFiles(path string) (files []string) {
+	ext := filepath.Ext(path)
+	prefix := filepath.Base(strings.TrimSuffix(path, ext)) + "."
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimSuffix(rest, ".gz")
+		stamp, ok := strings.CutSuffix(rest, ext)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(rotateStamp, stamp); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(filepath.Dir(path), name))
+	}
+	sort.Strings(files)
+
+	// Skip the compressed copy of a file that is still being compressed.
+	for i := len(files) - 1; i > 0; i-- {
+		if files[i] == files[i-1]+".gz" {
+			files = append(files[:i], files[i+1:]...)
+		}
+	}
+	return
+}
@@ -0,0 +1,247 @@
+package clog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	markLines = 4096    // Maximum number of lines between two marks.
+	markBytes = 1 << 20 // Maximum number of bytes between two marks.
+)
+
+// A checkpoint into a log file.
+type mark struct {
+	time   time.Time // The time of the first timed line after the offset, zero if unknown.
+	offset int64     // The offset of the line in the uncompressed contents.
+}
+
+// Sparse index of the line offsets of a log file by time.
+type fileIndex struct {
+	mu      sync.Mutex
+	file    os.FileInfo // The file that was indexed.
+	indexed int64       // Offset up to which the contents are indexed, always at a line boundary.
+	lines   int         // Number of lines since the last mark.
+	last    time.Time   // Time of the last timed line.
+	marks   []mark
+}
+
+var indexes sync.Map // path -> *fileIndex
+
+func (idx *fileIndex) add(line string, offset int64, t time.Time) {
+	n := len(idx.marks)
+	if n == 0 || idx.lines >= markLines || offset-idx.marks[n-1].offset >= markBytes {
+		idx.marks = append(idx.marks, mark{offset: offset})
+		idx.lines = 0
+		n++
+	}
+	idx.lines++
+	if !t.IsZero() {
+		if idx.marks[n-1].time.IsZero() {
+			idx.marks[n-1].time = t
+		}
+		idx.last = t
+	}
+}
+
+// Brings the index up to date with the file.
+func (idx *fileIndex) update(path string, timeOf func(string) time.Time) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if idx.file != nil && (!os.SameFile(idx.file, fi) || fi.Size() < idx.file.Size()) {
+		*idx = fileIndex{}
+	}
+	compressed := strings.HasSuffix(path, ".gz")
+	if idx.file != nil && (compressed || fi.Size() == idx.file.Size()) {
+		return nil
+	}
+
+	r, closer, err := openAt(path, idx.indexed)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || !compressed || line == "") {
+			break // Partial lines of the active file are indexed once they are complete.
+		}
+		idx.add(line, idx.indexed, timeOf(strings.TrimRight(line, "\r\n")))
+		idx.indexed += int64(len(line))
+	}
+	idx.file = fi
+	return nil
+}
+
+// Returns the range of offsets that may hold lines within the window.
+func (idx *fileIndex) window(since, until time.Time) (start, end int64, ok bool) {
+	if !since.IsZero() && !idx.last.IsZero() && idx.last.Before(since) {
+		return 0, 0, false
+	}
+	end = -1
+	for _, m := range idx.marks {
+		if m.time.IsZero() {
+			continue
+		}
+		if !since.IsZero() && !m.time.After(since) {
+			start = m.offset
+		}
+		if !until.IsZero() && m.time.After(until) {
+			end = m.offset
+			break
+		}
+	}
+	return start, end, end < 0 || start < end
+}
+
+// Opens the file positioned at the given offset of its uncompressed contents.
+func openAt(path string, offset int64) (r io.Reader, closer io.Closer, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		_, err = file.Seek(offset, io.SeekStart)
+		r = file
+	} else if r, err = gzip.NewReader(file); err == nil {
+		_, err = io.CopyN(io.Discard, r, offset)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return r, file, nil
+}
+
+type SearchQuery struct {
+	Text   string    // The text to look for, case insensitive.
+	Regex  bool      // Interprets the text as a regular expression.
+	Since  time.Time // Lower bound of the window, zero for none.
+	Until  time.Time // Upper bound of the window, zero for none.
+	Stream Stream    // The stream to search, StreamMax for all.
+	Limit  int       // Maximum number of results, the latest ones are kept.
+}
+type SearchResult struct {
+	Line  string
+	Entry Entry
+}
+
+func (q *SearchQuery) matcher() (func(string) bool, error) {
+	if q.Regex {
+		rgx, err := regexp.Compile("(?i)" + q.Text)
+		if err != nil {
+			return nil, err
+		}
+		return rgx.MatchString, nil
+	}
+	text := strings.ToLower(q.Text)
+	return func(line string) bool {
+		return strings.Contains(strings.ToLower(line), text)
+	}, nil
+}
+func (q *SearchQuery) inWindow(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || !t.After(q.Until))
+}
+
+func (logger *Logger) searchFile(path string, stream Stream, q *SearchQuery, match func(string) bool) (out []SearchResult, err error) {
+	v, _ := indexes.LoadOrStore(path, &fileIndex{})
+	idx := v.(*fileIndex)
+	idx.mu.Lock()
+	err = idx.update(path, func(line string) time.Time { return logger.ParseLine(line, stream).Time })
+	start, end, ok := idx.window(q.Since, q.Until)
+	idx.mu.Unlock()
+	if os.IsNotExist(err) {
+		indexes.Delete(path)
+	}
+	if err != nil || !ok {
+		return
+	}
+
+	r, closer, err := openAt(path, start)
+	if err != nil {
+		return
+	}
+	defer closer.Close()
+	br := bufio.NewReaderSize(r, 64*1024)
+	for offset := start; end < 0 || offset < end; {
+		line, err := br.ReadString('\n')
+		if line == "" {
+			break
+		}
+		offset += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" && match(line) {
+			if entry := logger.ParseLine(line, stream); q.inWindow(entry.Time) {
+				out = append(out, SearchResult{Line: line, Entry: entry})
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Keeps the latest limit results.
+func latest(results []SearchResult, limit int) []SearchResult {
+	if limit > 0 && len(results) > limit {
+		return results[len(results)-limit:]
+	}
+	return results
+}
+
+// Sorts the results by time, lines without a known time keep their position.
+func SortResults(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		ti, tj := results[i].Entry.Time, results[j].Entry.Time
+		return !ti.IsZero() && !tj.IsZero() && ti.Before(tj)
+	})
+}
+
+// Searches the log files of the logger including the rotated ones, returns the latest
+// matches in chronological order.
+func (logger *Logger) Search(q SearchQuery) (out []SearchResult, err error) {
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+	logger.mu.RLock()
+	paths := logger.Paths
+	logger.mu.RUnlock()
+
+	for stream, path := range paths {
+		if path == "" || (stream != 0 && path == paths[0]) || (q.Stream != StreamMax && q.Stream != Stream(stream)) {
+			continue
+		}
+
+		// Walk the files newest first until the limit is reached.
+		var results []SearchResult
+		files := append(RotatedFiles(path), path)
+		for i := len(files) - 1; i >= 0; i-- {
+			found, err := logger.searchFile(files[i], Stream(stream), &q, match)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			results = append(latest(found, q.Limit-len(results)), results...)
+			if q.Limit > 0 && len(results) >= q.Limit {
+				break
+			}
+		}
+		out = append(out, results...)
+	}
+	SortResults(out)
+	return latest(out, q.Limit), nil
+}
@@ -0,0 +1,187 @@
+package clog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/can1357/gosu/pkg/util"
+)
+
+type SinkOptions struct {
+	Type     string                `json:"type"`               // The kind of sink: syslog, journald or http.
+	Address  string                `json:"address,omitempty"`  // Where to send the lines, e.g. udp://host:514, unix:///dev/log or the URL to post to.
+	Format   string                `json:"format,omitempty"`   // The body format of the http sink: json, loki or elastic.
+	Tag      string                `json:"tag,omitempty"`      // The application name reported, defaults to the job.
+	Facility string                `json:"facility,omitempty"` // The syslog facility, defaults to user.
+	Headers  map[string]string     `json:"headers,omitempty"`  // Extra headers sent by the http sink.
+	Buffer   int                   `json:"buffer,omitempty"`   // The number of lines buffered before new ones are dropped.
+	Batch    int                   `json:"batch,omitempty"`    // The maximum number of lines sent at once.
+	Interval util.ParsableDuration `json:"interval,omitempty"` // The maximum time a line waits in the buffer.
+}
+
+func (o *SinkOptions) WithDefaults() {
+	if o.Buffer <= 0 {
+		o.Buffer = 4096
+	}
+	if o.Batch <= 0 {
+		o.Batch = 256
+	}
+	if !o.Interval.IsPositive() {
+		o.Interval = util.Duration(time.Second)
+	}
+}
+
+// The transport of a sink, called from a single goroutine.
+type sinkTransport interface {
+	Send(batch []*Entry) error
+	Close() error
+}
+
+// A hook shipping the lines to an external sink from a background goroutine, lines are
+// dropped rather than blocking the writer when the sink falls behind.
+type Sink struct {
+	opts      SinkOptions
+	transport sinkTransport
+	ch        chan *Entry
+	dropped   atomic.Int64
+	closed    atomic.Bool
+	quit      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+	lastError string
+}
+
+func NewSink(opts SinkOptions) (s *Sink, err error) {
+	opts.WithDefaults()
+	s = &Sink{
+		opts: opts,
+		ch:   make(chan *Entry, opts.Buffer),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	switch strings.ToLower(opts.Type) {
+	case "syslog":
+		s.transport, err = newSyslogTransport(opts)
+	case "journald":
+		s.transport, err = newJournaldTransport(opts)
+	case "http":
+		s.transport, err = newHttpTransport(opts)
+	default:
+		err = fmt.Errorf("unknown sink type: %q", opts.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	go s.run()
+	return
+}
+
+// Implements Hook.
+func (s *Sink) Write(line string, entry *Entry) {
+	if s.closed.Load() {
+		return
+	}
+	select {
+	case s.ch <- entry:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Flushes the buffered lines and closes the sink.
+func (s *Sink) Close() error {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.quit)
+	})
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+	}
+	return nil
+}
+
+// Reports sink failures on the daemon's stderr, never through a logger as it would loop back.
+func (s *Sink) report(err error) {
+	if err == nil {
+		s.lastError = ""
+	} else if msg := err.Error(); msg != s.lastError {
+		s.lastError = msg
+		fmt.Fprintf(os.Stderr, "log sink %s %s failed: %v\n", s.opts.Type, s.opts.Address, err)
+	}
+	if n := s.dropped.Swap(0); n != 0 {
+		fmt.Fprintf(os.Stderr, "log sink %s %s dropped %d line(s)\n", s.opts.Type, s.opts.Address, n)
+	}
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	defer s.transport.Close()
+
+	batch := make([]*Entry, 0, s.opts.Batch)
+	flush := func() {
+		if len(batch) != 0 {
+			s.report(s.transport.Send(batch))
+			batch = batch[:0]
+		}
+	}
+	ticker := time.NewTicker(s.opts.Interval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-s.ch:
+			if batch = append(batch, e); len(batch) >= s.opts.Batch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.quit:
+			for {
+				select {
+				case e := <-s.ch:
+					if batch = append(batch, e); len(batch) >= s.opts.Batch {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Syslog severity of the entry.
+func severity(e *Entry) int {
+	switch e.Level {
+	case "fatal", "panic":
+		return 2
+	case "error":
+		return 3
+	case "warn", "warning":
+		return 4
+	case "info":
+		return 6
+	case "debug", "trace":
+		return 7
+	}
+	if e.Stream == StreamStderr {
+		return 3
+	}
+	return 6
+}
+
+func (o *SinkOptions) tag(e *Entry) string {
+	switch {
+	case o.Tag != "":
+		return o.Tag
+	case e.Job != "":
+		return e.Job
+	default:
+		return "gosu"
+	}
+}
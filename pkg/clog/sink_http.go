@@ -0,0 +1,112 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Batches of lines posted as JSON, in a format Loki or Elasticsearch accept if requested.
+type httpTransport struct {
+	opts   SinkOptions
+	client *http.Client
+}
+
+func newHttpTransport(opts SinkOptions) (sinkTransport, error) {
+	if u, err := url.Parse(opts.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid http sink address: %q", opts.Address)
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = "json"
+	case "json", "loki", "elastic":
+	default:
+		return nil, fmt.Errorf("unknown http sink format: %s", opts.Format)
+	}
+	return &httpTransport{opts: opts, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+type elasticDoc struct {
+	Timestamp time.Time `json:"@timestamp"`
+	*Entry
+}
+
+func (t *httpTransport) body(batch []*Entry) (body []byte, contentType string, err error) {
+	switch t.opts.Format {
+	case "loki":
+		streams := map[string]*lokiStream{}
+		var order []*lokiStream
+		for _, e := range batch {
+			key := e.Namespace + "\x00" + e.Stream.String() + "\x00" + e.Level
+			s := streams[key]
+			if s == nil {
+				s = &lokiStream{Stream: map[string]string{
+					"job":       t.opts.tag(e),
+					"namespace": e.Namespace,
+					"stream":    e.Stream.String(),
+				}}
+				if e.Level != "" {
+					s.Stream["level"] = e.Level
+				}
+				streams[key] = s
+				order = append(order, s)
+			}
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), e.Message})
+		}
+		body, err = json.Marshal(map[string]any{"streams": order})
+		return body, "application/json", err
+	case "elastic":
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range batch {
+			buf.WriteString(`{"index":{}}` + "\n")
+			if err = enc.Encode(elasticDoc{e.Time, e}); err != nil {
+				return
+			}
+		}
+		return buf.Bytes(), "application/x-ndjson", nil
+	default:
+		body, err = json.Marshal(batch)
+		return body, "application/json", err
+	}
+}
+
+func (t *httpTransport) Send(batch []*Entry) error {
+	body, contentType, err := t.body(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", t.opts.Address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range t.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+func (t *httpTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,93 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The journald native protocol over its datagram socket.
+type journaldTransport struct {
+	opts    SinkOptions
+	address string
+	conn    net.Conn
+}
+
+func newJournaldTransport(opts SinkOptions) (sinkTransport, error) {
+	t := &journaldTransport{opts: opts, address: "/run/systemd/journal/socket"}
+	if opts.Address != "" {
+		_, t.address = splitSinkAddress(opts.Address)
+	}
+	return t, nil
+}
+
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if strings.ContainsRune(value, '\n') {
+		buf.WriteByte('\n')
+		binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	} else {
+		buf.WriteByte('=')
+	}
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// Converts a field name to the journal's convention, returns false if it cannot be represented.
+func journalName(k string) (string, bool) {
+	k = strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, k)
+	k = strings.TrimLeft(k, "_")
+	return k, k != "" && len(k) <= 64 && !unicode.IsDigit(rune(k[0]))
+}
+
+func (t *journaldTransport) Send(batch []*Entry) (err error) {
+	if t.conn == nil {
+		if t.conn, err = net.Dial("unixgram", t.address); err != nil {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	for _, e := range batch {
+		buf.Reset()
+		writeJournalField(&buf, "MESSAGE", e.Message)
+		writeJournalField(&buf, "PRIORITY", strconv.Itoa(severity(e)))
+		writeJournalField(&buf, "SYSLOG_IDENTIFIER", t.opts.tag(e))
+		writeJournalField(&buf, "GOSU_JOB", e.Job)
+		writeJournalField(&buf, "GOSU_NAMESPACE", e.Namespace)
+		writeJournalField(&buf, "GOSU_STREAM", e.Stream.String())
+		for k, v := range e.Fields {
+			name, ok := journalName(k)
+			if !ok {
+				continue
+			}
+			str, ok := v.(string)
+			if !ok {
+				js, _ := json.Marshal(v)
+				str = string(js)
+			}
+			writeJournalField(&buf, name, str)
+		}
+		if _, err = t.conn.Write(buf.Bytes()); err != nil {
+			t.Close()
+			return
+		}
+	}
+	return nil
+}
+func (t *journaldTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
@@ -0,0 +1,128 @@
+package clog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// RFC5424 syslog over udp, tcp or a unix socket.
+type syslogTransport struct {
+	opts     SinkOptions
+	network  string
+	address  string
+	facility int
+	hostname string
+	conn     net.Conn
+}
+
+// Splits an address such as udp://host:514 into its network and address.
+func splitSinkAddress(addr string) (network, address string) {
+	if network, address, ok := strings.Cut(addr, "://"); ok {
+		return strings.ToLower(network), address
+	}
+	if strings.HasPrefix(addr, "/") {
+		return "unix", addr
+	}
+	return "udp", addr
+}
+
+func newSyslogTransport(opts SinkOptions) (sinkTransport, error) {
+	t := &syslogTransport{opts: opts, facility: 1}
+	if opts.Address == "" {
+		opts.Address = "unix:///dev/log"
+	}
+	t.network, t.address = splitSinkAddress(opts.Address)
+	switch t.network {
+	case "udp", "tcp", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", t.network)
+	}
+	if opts.Facility != "" {
+		f, ok := syslogFacilities[strings.ToLower(opts.Facility)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility: %s", opts.Facility)
+		}
+		t.facility = f
+	}
+	if t.hostname, _ = os.Hostname(); t.hostname == "" {
+		t.hostname = "-"
+	}
+	return t, nil
+}
+
+func (t *syslogTransport) dial() (err error) {
+	if t.conn != nil {
+		return nil
+	}
+	if t.network == "unix" {
+		// The local syslog socket is usually a datagram one.
+		if t.conn, err = net.DialTimeout("unixgram", t.address, 5*time.Second); err == nil {
+			return
+		}
+	}
+	t.conn, err = net.DialTimeout(t.network, t.address, 5*time.Second)
+	return
+}
+
+// Replaces the characters not allowed in header fields.
+func syslogName(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (t *syslogTransport) format(e *Entry) string {
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		t.facility*8+severity(e),
+		e.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		t.hostname,
+		syslogName(t.opts.tag(e), 48),
+		e.Stream,
+		e.Message,
+	)
+}
+
+func (t *syslogTransport) Send(batch []*Entry) error {
+	if err := t.dial(); err != nil {
+		return err
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	for _, e := range batch {
+		msg := t.format(e)
+		if t.network == "tcp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg) // Octet counting framing.
+		}
+		if _, err := t.conn.Write([]byte(msg)); err != nil {
+			t.Close()
+			return err
+		}
+	}
+	return nil
+}
+func (t *syslogTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
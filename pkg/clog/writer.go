@@ -2,6 +2,7 @@ package clog
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"strings"
 	"sync"
@@ -11,8 +12,8 @@ import (
 // Hooking system to inject listeners into all loggers.
 type Hook interface {
 	// Called when a logger is writing a line.
-	// The line is already formatted.
-	Write(ns string, line string, kind Stream)
+	// The line is already formatted, the entry is its structured form.
+	Write(line string, entry *Entry)
 }
 
 var hooks sync.Map
@@ -28,9 +29,14 @@ func RemoveHook(hook Hook) {
 type Writer struct {
 	owner            *Logger
 	mu               sync.Mutex
-	buffer           strings.Builder
+	buffer           strings.Builder // The formatted record.
+	message          strings.Builder // The record without the prefixes.
+	lines            int             // Number of lines in the record.
+	partial          bool            // True if the last line of the record is not complete yet.
+	timer            *time.Timer     // Flushes a grouped record once no continuation arrives.
 	underlyingStream io.Writer
 	kind             Stream
+	start            time.Time // The time the current record was started at.
 }
 
 func createWriter(owner *Logger, underlyingStream io.Writer, kind Stream) *Writer {
@@ -47,6 +53,7 @@ func forEachLine(p []byte, out func(data []byte, ended bool)) (n int) {
 		nl := bytes.IndexByte(p, '\n')
 		if nl == -1 {
 			out(p, false)
+			n += len(p)
 			break
 		}
 		nl++
@@ -58,6 +65,12 @@ func forEachLine(p []byte, out func(data []byte, ended bool)) (n int) {
 }
 
 func (f *Writer) WritePrefix() {
+	if f.buffer.Len() == 0 {
+		f.start = time.Now()
+	}
+	if f.owner.Format == FormatJson {
+		return
+	}
 	if f.owner.Timestamp != "" {
 		f.buffer.WriteString(time.Now().Format(f.owner.Timestamp))
 	}
@@ -68,13 +81,48 @@ func (f *Writer) WritePrefix() {
 }
 
 func (f *Writer) Flush() (e error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flushLocked()
+}
+func (f *Writer) flushLocked() (e error) {
 	result := f.buffer.String()
+	message := f.message.String()
 	f.buffer.Reset()
+	f.message.Reset()
+	f.lines = 0
+	f.partial = false
+	if f.timer != nil {
+		f.timer.Stop()
+		f.owner.grouped.Delete(f)
+	}
+	if result == "" && message == "" {
+		return
+	}
+
+	entry := &Entry{
+		Time:      f.start,
+		Job:       f.owner.Job(),
+		Namespace: f.owner.Namespace,
+		Stream:    f.kind,
+		Message:   strings.TrimRight(message, "\r\n"),
+	}
+	if f.owner.Parse {
+		entry.parseMessage()
+	}
+	if f.owner.Format == FormatJson {
+		if js, err := json.Marshal(entry); err == nil {
+			result = string(js) + "\n"
+		}
+	}
 
 	hooks.Range(func(key any, value any) bool {
-		key.(Hook).Write(f.owner.Namespace, result, f.kind)
+		key.(Hook).Write(result, entry)
 		return true
 	})
+	for _, sink := range f.owner.sinks {
+		sink.Write(result, entry)
+	}
 
 	if f.underlyingStream != nil {
 		_, e = f.underlyingStream.Write([]byte(result))
@@ -82,17 +130,47 @@ func (f *Writer) Flush() (e error) {
 	return
 }
 
+// Flushes the grouped record if no continuation arrived in time.
+func (f *Writer) flushIdle() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.partial {
+		f.flushLocked()
+	}
+}
+
 func (f *Writer) Write(input []byte) (n int, err error) {
+	group := f.owner.group
 	n = forEachLine(input, func(data []byte, isEnd bool) {
 		f.mu.Lock()
 		defer f.mu.Unlock()
 
-		if f.buffer.Len() == 0 {
+		if !f.partial {
+			// A new line either continues the record or replaces it.
+			if f.lines != 0 && (group == nil || !group.continues(data) || f.lines >= maxGroupLines) {
+				if e := f.flushLocked(); e != nil {
+					err = e
+				}
+			}
 			f.WritePrefix()
+			f.lines++
 		}
 		f.buffer.Write(data)
+		f.message.Write(data)
+		f.partial = !isEnd
 		if isEnd {
-			err = f.Flush()
+			if group == nil {
+				if e := f.flushLocked(); e != nil {
+					err = e
+				}
+			} else {
+				if f.timer == nil {
+					f.timer = time.AfterFunc(group.timeout, f.flushIdle)
+				} else {
+					f.timer.Reset(group.timeout)
+				}
+				f.owner.grouped.Store(f, struct{}{})
+			}
 		}
 	})
 	return
@@ -48,14 +48,14 @@ func UnmarshalDynamicWith(execute Executor, ctx context.Context, path string, co
 		}
 	}
 
-	code = strings.ReplaceAll(code, "@", strings.ReplaceAll(path, "\\", "\\\\"))
-	code = strings.ReplaceAll(code, "$", escapeString(paramsEncoded))
-
 	var ty string = "--experimental-default-type=module"
 	if code == UnmarshalWrapperCJS {
 		ty = "--experimental-default-type=commonjs"
 	}
 
+	code = strings.ReplaceAll(code, "@", strings.ReplaceAll(path, "\\", "\\\\"))
+	code = strings.ReplaceAll(code, "$", escapeString(paramsEncoded))
+
 	var cmd *exec.Cmd
 	cmd, err = execute(ctx, ty, "-e", code)
 	if err != nil {
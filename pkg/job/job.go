@@ -3,10 +3,13 @@ package job
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/can1357/gosu/pkg/clog"
 	"github.com/can1357/gosu/pkg/settings"
@@ -16,27 +19,35 @@ import (
 
 type LoggerOptions = clog.Options
 type Manifest struct {
-	ID            string    `json:"id,omitempty"` // The task's ID.
-	Main          task.Task `json:"main"`         // The main task to run.
-	Launch        *Trigger  `json:"launch,omitempty"`
-	Drop          *Trigger  `json:"drop,omitempty"`
-	task.Options            // The task options.
-	LoggerOptions           // The log configuration.
+	ID            string              `json:"id,omitempty"` // The task's ID.
+	Main          task.Task           `json:"main"`         // The main task to run.
+	Launch        *Trigger            `json:"launch,omitempty"`
+	Drop          *Trigger            `json:"drop,omitempty"`
+	Restart       *Trigger            `json:"restart,omitempty"`       // Restarts the job if it is running.
+	Build         *task.Task          `json:"build,omitempty"`         // Task to run before restarts, the restart is skipped if it fails.
+	ReloadSignal  util.ParsableSignal `json:"reload_signal,omitempty"` // Signal sent to the processes on reload instead of replacing them.
+	Labels        map[string]string   `json:"labels,omitempty"`        // Extra labels attached to the metrics of the job.
+	Project       string              `json:"project,omitempty"`       // The project the job was declared in, the path of its file unless named, see gosu up.
+	task.Options                      // The task options.
+	LoggerOptions                     // The log configuration.
 }
 
 type Job struct {
 	context.Context
-	Manifest   *Manifest
-	ID         string
-	Main       task.Task
-	Cancel     context.CancelFunc
-	Options    task.Options
-	Logger     *clog.Logger
-	Launch     *Trigger
-	Drop       *Trigger
-	mu         sync.Mutex
-	worker     task.Worker
-	Whiteboard atomic.Pointer[task.Whiteboard]
+	Manifest     *Manifest
+	ID           string
+	Main         task.Task
+	Cancel       context.CancelFunc
+	Options      task.Options
+	Logger       *clog.Logger
+	Launch       *Trigger
+	Drop         *Trigger
+	RestartOn    *Trigger
+	Build        *task.Task
+	ReloadSignal util.ParsableSignal
+	mu           sync.Mutex
+	worker       task.Worker
+	Whiteboard   atomic.Pointer[task.Whiteboard]
 }
 
 func Parse(data string) (j *Job, err error) {
@@ -52,7 +63,7 @@ func (s *Job) Worker() task.Worker {
 }
 
 func (s *Job) startLocked() (w task.Worker, started bool) {
-	if s.worker != nil {
+	if s.worker != nil && s.worker.Err() == nil {
 		return s.worker, false
 	}
 	worker := task.NewWorker(s.Context, s.Main, s.Options)
@@ -82,13 +93,106 @@ func (s *Job) Stop() {
 	fmt.Printf("Stopping job %s\n", s.ID)
 	s.stopLocked()
 }
-func (s *Job) Restart() {
+
+// Stops the job and releases its triggers and logger, the job cannot be started again.
+func (s *Job) Close() {
+	s.Stop()
+	s.mu.Lock()
+	cancel := s.Cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if s.Logger != nil {
+		s.Logger.Close()
+	}
+}
+
+// Rebuilds the job if it has a build step and restarts it, the instances are kept if the build fails.
+func (s *Job) Restart() error {
+	if err := s.build(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.restartLocked()
+	return nil
+}
+func (s *Job) restartLocked() {
 	fmt.Printf("Restarting job %s\n", s.ID)
 	s.stopLocked()
 	s.startLocked()
 }
+
+// Replaces the running instances without downtime if the task supports it, restarts the job otherwise.
+func (s *Job) Reload() error {
+	if w := s.Worker(); w == nil || w.Err() != nil {
+		return task.Idle
+	}
+	if err := s.build(); err != nil {
+		return err
+	}
+	if !s.ReloadSignal.IsZero() {
+		return s.Signal(s.ReloadSignal.Signal)
+	}
+	fmt.Printf("Reloading job %s\n", s.ID)
+	err := s.Worker().Reload()
+	if errors.Is(err, task.ErrReloadUnsupported) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.restartLocked()
+		return nil
+	}
+	return err
+}
+
+// Delivers the signal to every process of the job.
+func (s *Job) Signal(sig os.Signal) error {
+	w := s.Worker()
+	if w == nil || w.Err() != nil {
+		return task.Idle
+	}
+	fmt.Printf("Sending %s to job %s\n", util.Signal(sig), s.ID)
+	return w.Signal(sig)
+}
+
+// Runs the build step, as a child of the running worker if there is one.
+func (s *Job) build() (err error) {
+	if s.Build == nil {
+		return nil
+	}
+	fmt.Printf("Building job %s\n", s.ID)
+	once := func(o *task.Options) {
+		o.RetryDisabled = true
+		o.MinUptime = util.Duration(0)
+	}
+	w := s.Worker()
+	if l, ok := w.(task.Launcher); ok && w.Err() == nil {
+		err = <-l.Launch(w, *s.Build, once)
+	} else {
+		opts := s.Options
+		once(&opts)
+		err = task.NewWorker(s.Context, *s.Build, opts).Run()
+	}
+	if err != nil {
+		fmt.Fprintf(s.Logger.Stderr(), "Build failed, keeping the current instances: %v\n", err)
+	}
+	return
+}
+func (s *Job) restartIfRunning() {
+	if w := s.Worker(); w == nil || w.Err() != nil {
+		return
+	}
+	if s.build() != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.worker == nil || s.worker.Err() != nil {
+		return
+	}
+	s.restartLocked()
+}
 func (s *Job) Join(c context.Context) error {
 	work := s.Worker()
 	if work == nil {
@@ -117,6 +221,14 @@ func (s *Job) Status() task.StatusOrError {
 	}
 	return task.Idle
 }
+func (s *Job) NextLaunch() time.Time {
+	if s.Launch != nil {
+		if sched, ok := s.Launch.ITrigger.(ScheduledTrigger); ok {
+			return sched.Next(time.Now())
+		}
+	}
+	return time.Time{}
+}
 func (s *Job) Traverse(fn func(task.Worker) bool) {
 	work := s.Worker()
 	if work != nil {
@@ -142,13 +254,45 @@ func (j *Job) Ready(ctx context.Context) {
 		cancel := trigger.Listen(j.Stop)
 		context.AfterFunc(j.Context, cancel)
 	}
+	if trigger := j.RestartOn; trigger != nil {
+		cancel := trigger.Listen(j.restartIfRunning)
+		context.AfterFunc(j.Context, cancel)
+	}
+}
+
+// Returns the working directory of the first process the task runs.
+func taskCwd(t task.Task) string {
+	switch t := t.ITask.(type) {
+	case *task.TaskRun:
+		return t.Cwd
+	case *task.Pipe:
+		for _, sub := range t.Subtasks {
+			if cwd := taskCwd(sub); cwd != "" {
+				return cwd
+			}
+		}
+	}
+	return ""
 }
 func (recipe *Manifest) Spawn() (j *Job, err error) {
 	j = &Job{}
 	err = recipe.SpawnAt(j)
 	return
 }
+
+// Returns true if the main task of the job declares the environment profile.
+func (recipe *Manifest) HasProfile(name string) bool {
+	run, ok := recipe.Main.ITask.(*task.TaskRun)
+	if !ok {
+		return false
+	}
+	_, ok = run.Profiles[name]
+	return ok
+}
 func (recipe *Manifest) SpawnAt(j *Job) (err error) {
+	if recipe.Profile != "" && !recipe.HasProfile(recipe.Profile) {
+		return fmt.Errorf("unknown environment profile: %s", recipe.Profile)
+	}
 	j.Options = recipe.Options
 	j.ID = recipe.ID
 	logOpts := recipe.LoggerOptions
@@ -170,6 +314,22 @@ func (recipe *Manifest) SpawnAt(j *Job) (err error) {
 	}
 	j.Drop = recipe.Drop
 	j.Launch = recipe.Launch
+	j.RestartOn = recipe.Restart
+	j.ReloadSignal = recipe.ReloadSignal
+	if recipe.Build != nil {
+		build := *recipe.Build
+		if build.ID.ID == "" {
+			build.ID.ID = "build"
+		}
+		j.Build = &build
+	}
+	cwd := taskCwd(j.Main)
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+	for _, trigger := range []*Trigger{j.Launch, j.Drop, j.RestartOn} {
+		trigger.BindCwd(cwd)
+	}
 	j.Manifest = recipe
 	return nil
 }
@@ -0,0 +1,270 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/can1357/gosu/pkg/foreign"
+	"github.com/samber/lo"
+)
+
+// The applications declared by a PM2 ecosystem file.
+type Pm2Ecosystem struct {
+	Apps []map[string]any `json:"apps"`
+}
+
+// Evaluates the ecosystem file, JavaScript files are run through the foreign unmarshalers.
+func ReadPm2Ecosystem(path string) (e *Pm2Ecosystem, err error) {
+	e = &Pm2Ecosystem{}
+	if err = foreign.Unmarshal(path, nil, e); err != nil {
+		return nil, err
+	}
+	if len(e.Apps) == 0 {
+		return nil, fmt.Errorf("%s declares no apps", path)
+	}
+	return
+}
+
+// Maps the apps onto manifests, relative paths are resolved against dir. The env_<name>
+// variables become environment profiles, the given one is selected. Keys gosu has no
+// equivalent for are reported as warnings.
+func (e *Pm2Ecosystem) Manifests(dir, profile string) (mfs []Manifest, warnings []string, err error) {
+	for i, app := range e.Apps {
+		name := fmt.Sprintf("apps[%d]", i)
+		if n, ok := app["name"].(string); ok && n != "" {
+			name = n
+		}
+		mf, warns, err := pm2Manifest(app, dir, profile)
+		for _, w := range warns {
+			warnings = append(warnings, name+": "+w)
+		}
+		if err != nil {
+			return nil, warnings, fmt.Errorf("%s: %w", name, err)
+		}
+		mfs = append(mfs, mf)
+	}
+	return
+}
+
+func pm2String(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func pm2Strings(v any) []string {
+	switch v := v.(type) {
+	case []any:
+		return lo.Map(v, func(s any, _ int) string { return pm2String(s) })
+	case string:
+		return strings.Fields(v)
+	default:
+		return nil
+	}
+}
+
+func pm2Env(v any, dst map[string]string) {
+	vars, _ := v.(map[string]any)
+	for k, v := range vars {
+		dst[k] = pm2String(v)
+	}
+}
+
+// PM2 durations are numbers of milliseconds.
+func pm2Duration(v any) string {
+	if f, ok := v.(float64); ok {
+		return fmt.Sprintf("%dms", int64(f))
+	}
+	return pm2String(v)
+}
+
+// 0 and "max" run an instance per CPU, negative counts leave that many CPUs free.
+func pm2Instances(v any) (int, error) {
+	s := pm2String(v)
+	if s == "max" {
+		return runtime.NumCPU(), nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid instances: %q", s)
+	}
+	if n <= 0 {
+		n = max(runtime.NumCPU()+n, 1)
+	}
+	return n, nil
+}
+
+func pm2Manifest(app map[string]any, dir, profile string) (mf Manifest, warnings []string, err error) {
+	doc := map[string]any{}
+	run := map[string]any{}
+	env := map[string]string{}
+	var script, cwd, interpreter, stdout, stderr string
+	var args, watch, ignore []string
+	var cron string
+	var n int
+	var cluster, watching bool
+
+	keys := lo.Keys(app)
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := app[key]
+		switch key {
+		case "name":
+			doc["id"] = pm2String(v)
+		case "script":
+			script = pm2String(v)
+		case "args":
+			args = pm2Strings(v)
+		case "cwd":
+			cwd = pm2String(v)
+		case "interpreter", "exec_interpreter":
+			interpreter = pm2String(v)
+		case "instances":
+			if n, err = pm2Instances(v); err != nil {
+				return
+			}
+		case "exec_mode":
+			switch mode := strings.TrimSuffix(pm2String(v), "_mode"); mode {
+			case "cluster":
+				cluster = true
+			case "fork":
+			default:
+				warnings = append(warnings, fmt.Sprintf("unknown exec_mode %q, running as fork", mode))
+			}
+		case "env":
+			pm2Env(v, env)
+		case "env_file":
+			run["env_file"] = pm2Strings(v)
+		case "max_memory_restart":
+			doc["max_memory"] = v
+		case "cron_restart":
+			cron = pm2String(v)
+		case "watch":
+			if b, ok := v.(bool); ok {
+				watching = b
+			} else {
+				watch = pm2Strings(v)
+				watching = len(watch) != 0
+			}
+		case "ignore_watch":
+			ignore = pm2Strings(v)
+		case "out_file":
+			stdout = pm2String(v)
+		case "error_file":
+			stderr = pm2String(v)
+		case "kill_timeout":
+			doc["stop_timeout"] = pm2Duration(v)
+		case "min_uptime":
+			doc["min_uptime"] = pm2Duration(v)
+		case "restart_delay":
+			doc["retry_backoff"] = pm2Duration(v)
+		case "autorestart":
+			if v == false {
+				warnings = append(warnings, "autorestart false is not supported, the job is restarted when it fails")
+			}
+		case "merge_logs", "combine_logs":
+			// The lines of every instance always go to the same files.
+		default:
+			if after, found := strings.CutPrefix(key, "env_"); found {
+				vars := map[string]string{}
+				pm2Env(v, vars)
+				run["env_"+after] = vars
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("unsupported key %q ignored", key))
+		}
+	}
+	if script == "" {
+		return mf, warnings, errors.New("missing script")
+	}
+
+	// Resolve the paths the way PM2 does, relative to the working directory of the app.
+	if !filepath.IsAbs(cwd) {
+		cwd = filepath.Join(dir, cwd)
+	}
+	run["cwd"] = cwd
+	resolve := func(path string) string {
+		switch {
+		case path == "/dev/null":
+			return "null"
+		case path != "" && !filepath.IsAbs(path):
+			return filepath.Join(cwd, path)
+		}
+		return path
+	}
+	if stdout = resolve(stdout); stdout != "" {
+		doc["stdout"] = stdout
+	}
+	if stderr = resolve(stderr); stderr != "" {
+		doc["stderr"] = stderr
+	}
+
+	// Pick the runtime from the interpreter, or the extension of the script.
+	kind := "run"
+	switch interpreter {
+	case "node", "nodejs", "bun":
+		kind = "js"
+	case "ts-node", "tsx":
+		kind = "ts"
+	case "", "none":
+		switch strings.ToLower(filepath.Ext(script)) {
+		case ".js", ".cjs", ".mjs":
+			kind = "js"
+		case ".ts", ".cts", ".mts":
+			kind = "ts"
+		}
+	default:
+		args = append([]string{script}, args...)
+		script = interpreter
+	}
+	if kind != "run" || strings.ContainsRune(script, '/') {
+		script = resolve(script)
+	}
+	run["exec"] = script
+	run["args"] = args
+
+	run["env"] = env
+	if profile != "" {
+		doc["env_profile"] = profile
+	}
+	if n > 1 {
+		run["n"] = n
+		if cluster {
+			warnings = append(warnings, "cluster mode does not share the port, add a proxy and listen on GOSU_SERVE")
+		}
+	}
+	doc["main"] = map[string]any{kind: run}
+
+	var restart []any
+	if watching {
+		pattern := strings.Join(append(watch, lo.Map(ignore, func(s string, _ int) string { return "!" + s })...), " ")
+		restart = append(restart, strings.TrimSpace("watch "+pattern))
+	}
+	if cron != "" {
+		restart = append(restart, "cron:"+cron)
+	}
+	if len(restart) == 1 {
+		doc["restart"] = restart[0]
+	} else if len(restart) > 1 {
+		doc["restart"] = restart
+	}
+
+	js, err := json.Marshal(doc)
+	if err == nil {
+		err = json.Unmarshal(js, &mf)
+	}
+	return
+}
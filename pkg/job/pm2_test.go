@@ -0,0 +1,245 @@
+package job
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/can1357/gosu/pkg/task"
+)
+
+func TestPm2Manifest(t *testing.T) {
+	tests := []struct {
+		name         string
+		app          map[string]any
+		profile      string
+		wantErr      bool
+		wantForeign  string
+		wantExec     string
+		wantArgs     []string
+		wantCwd      string
+		wantN        int
+		wantEnv      map[string]string
+		wantProfiles map[string]map[string]string
+		wantStdout   string
+		wantStderr   string
+		wantRestart  []string
+		wantWarnings []string
+	}{
+		{
+			name:        "node script",
+			app:         map[string]any{"name": "api", "script": "app.js", "args": "--port 80"},
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantArgs:    []string{"--port", "80"},
+			wantCwd:     "/srv",
+		},
+		{
+			name:        "typescript in a sub directory",
+			app:         map[string]any{"script": "src/main.ts", "cwd": "api"},
+			wantForeign: "ts",
+			wantExec:    "/srv/api/src/main.ts",
+			wantCwd:     "/srv/api",
+		},
+		{
+			name:     "interpreter",
+			app:      map[string]any{"script": "worker.py", "interpreter": "python3", "args": []any{"-q", float64(2)}},
+			wantExec: "python3",
+			wantArgs: []string{"worker.py", "-q", "2"},
+			wantCwd:  "/srv",
+		},
+		{
+			name:     "binary on the path",
+			app:      map[string]any{"script": "redis-server", "cwd": "/var/lib/redis"},
+			wantExec: "redis-server",
+			wantCwd:  "/var/lib/redis",
+		},
+		{
+			name:     "local binary",
+			app:      map[string]any{"script": "./bin/server"},
+			wantExec: "/srv/bin/server",
+			wantCwd:  "/srv",
+		},
+		{
+			name:        "environment",
+			app:         map[string]any{"script": "app.js", "env": map[string]any{"PORT": float64(3000), "DEBUG": "1"}, "env_production": map[string]any{"PORT": float64(80)}},
+			profile:     "production",
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantCwd:     "/srv",
+			wantEnv:     map[string]string{"PORT": "3000", "DEBUG": "1"},
+			wantProfiles: map[string]map[string]string{
+				"production": {"PORT": "80"},
+			},
+		},
+		{
+			name:        "cluster",
+			app:         map[string]any{"script": "app.js", "instances": float64(4), "exec_mode": "cluster_mode"},
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantCwd:     "/srv",
+			wantN:       4,
+			wantWarnings: []string{
+				"cluster mode does not share the port, add a proxy and listen on GOSU_SERVE",
+			},
+		},
+		{
+			name:        "logs",
+			app:         map[string]any{"script": "app.js", "out_file": "logs/out.log", "error_file": "/dev/null"},
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantCwd:     "/srv",
+			wantStdout:  "/srv/logs/out.log",
+			wantStderr:  "null",
+		},
+		{
+			name:        "watch and cron",
+			app:         map[string]any{"script": "app.js", "watch": []any{"src"}, "ignore_watch": []any{"dist"}, "cron_restart": "0 3 * * *"},
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantCwd:     "/srv",
+			wantRestart: []string{"watch", "cron"},
+		},
+		{
+			name:        "watch",
+			app:         map[string]any{"script": "app.js", "watch": true},
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantCwd:     "/srv",
+			wantRestart: []string{"watch"},
+		},
+		{
+			name:        "unsupported keys",
+			app:         map[string]any{"script": "app.js", "autorestart": false, "exec_mode": "spawn", "vizion": false, "merge_logs": true},
+			wantForeign: "js",
+			wantExec:    "/srv/app.js",
+			wantCwd:     "/srv",
+			wantWarnings: []string{
+				"autorestart false is not supported, the job is restarted when it fails",
+				`unknown exec_mode "spawn", running as fork`,
+				`unsupported key "vizion" ignored`,
+			},
+		},
+		{
+			name:    "missing script",
+			app:     map[string]any{"name": "api"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid instances",
+			app:     map[string]any{"script": "app.js", "instances": "many"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mf, warnings, err := pm2Manifest(tt.app, "/srv", tt.profile)
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+			if err != nil || tt.wantErr {
+				if (err != nil) != tt.wantErr {
+					t.Errorf("err = %v, want error = %v", err, tt.wantErr)
+				}
+				return
+			}
+			run, ok := mf.Main.ITask.(*task.TaskRun)
+			if !ok {
+				t.Fatalf("main task is %T, want a run task", mf.Main.ITask)
+			}
+			if run.Foreign != tt.wantForeign || run.Exec != tt.wantExec || run.Cwd != tt.wantCwd {
+				t.Errorf("main = %s %q in %q, want %s %q in %q", run.Foreign, run.Exec, run.Cwd, tt.wantForeign, tt.wantExec, tt.wantCwd)
+			}
+			if len(run.Args) != 0 || len(tt.wantArgs) != 0 {
+				if !reflect.DeepEqual(run.Args, tt.wantArgs) {
+					t.Errorf("args = %q, want %q", run.Args, tt.wantArgs)
+				}
+			}
+			if run.N != tt.wantN {
+				t.Errorf("n = %d, want %d", run.N, tt.wantN)
+			}
+			if len(run.Env) != 0 || len(tt.wantEnv) != 0 {
+				if !reflect.DeepEqual(run.Env, tt.wantEnv) {
+					t.Errorf("env = %v, want %v", run.Env, tt.wantEnv)
+				}
+			}
+			if !reflect.DeepEqual(run.Profiles, tt.wantProfiles) {
+				t.Errorf("profiles = %v, want %v", run.Profiles, tt.wantProfiles)
+			}
+			if mf.Profile != tt.profile {
+				t.Errorf("profile = %q, want %q", mf.Profile, tt.profile)
+			}
+			if mf.Output != tt.wantStdout || mf.Error != tt.wantStderr {
+				t.Errorf("logs = %q, %q, want %q, %q", mf.Output, mf.Error, tt.wantStdout, tt.wantStderr)
+			}
+			var restart []string
+			if mf.Restart != nil {
+				if list, ok := mf.Restart.ITrigger.(TriggerAny); ok {
+					for _, t := range list.List {
+						restart = append(restart, t.Kind)
+					}
+				} else {
+					restart = []string{mf.Restart.Kind}
+				}
+			}
+			if !reflect.DeepEqual(restart, tt.wantRestart) {
+				t.Errorf("restart = %q, want %q", restart, tt.wantRestart)
+			}
+		})
+	}
+}
+
+func TestPm2ManifestDurations(t *testing.T) {
+	app := map[string]any{
+		"script":             "app.js",
+		"kill_timeout":       float64(1600),
+		"min_uptime":         "10s",
+		"restart_delay":      float64(250),
+		"max_memory_restart": "300M",
+	}
+	mf, _, err := pm2Manifest(app, "/srv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"kill_timeout", mf.StopTimeout.Duration, 1600 * time.Millisecond},
+		{"min_uptime", mf.MinUptime.Duration, 10 * time.Second},
+		{"restart_delay", mf.RetryBackoff.Duration, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if mf.MaxMemory.IsZero() {
+		t.Error("max_memory_restart is not mapped")
+	}
+}
+
+func TestPm2Instances(t *testing.T) {
+	cpus := runtime.NumCPU()
+	tests := []struct {
+		in      any
+		want    int
+		wantErr bool
+	}{
+		{float64(3), 3, false},
+		{"3", 3, false},
+		{"max", cpus, false},
+		{float64(0), cpus, false},
+		{float64(-1), max(cpus-1, 1), false},
+		{float64(-cpus - 1), 1, false},
+		{"many", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := pm2Instances(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("pm2Instances(%v) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
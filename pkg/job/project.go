@@ -0,0 +1,36 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/can1357/gosu/pkg/foreign"
+	"github.com/samber/lo"
+)
+
+// Reads a project file, a map of job ids to their manifests. Entries without a main task
+// are the main task themselves, e.g. {"api": {"ts": {"exec": "./api.ts"}}} or {"api": "run ./api"}.
+func ReadProject(path string) (mfs []Manifest, err error) {
+	var doc map[string]any
+	if err = foreign.Unmarshal(path, nil, &doc); err != nil {
+		return nil, err
+	}
+	ids := lo.Keys(doc)
+	sort.Strings(ids)
+	for _, id := range ids {
+		var mf Manifest
+		js, _ := json.Marshal(doc[id])
+		if entry, ok := doc[id].(map[string]any); ok && entry["main"] != nil {
+			err = json.Unmarshal(js, &mf)
+		} else {
+			err = json.Unmarshal(js, &mf.Main)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+		mf.ID = id
+		mfs = append(mfs, mf)
+	}
+	return
+}
@@ -2,6 +2,7 @@ package job
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/can1357/gosu/pkg/automarshal"
 )
@@ -10,6 +11,16 @@ type ITrigger interface {
 	Listen(callback func()) (remove func())
 }
 
+// Implemented by triggers that resolve paths relative to the job's working directory.
+type CwdTrigger interface {
+	BindCwd(cwd string)
+}
+
+// Implemented by triggers that fire at predictable times.
+type ScheduledTrigger interface {
+	Next(after time.Time) time.Time
+}
+
 type Trigger struct {
 	ITrigger
 	automarshal.ID
@@ -36,6 +47,31 @@ func (t TriggerAny) Listen(callback func()) (remove func()) {
 	}
 }
 
+func (t TriggerAny) BindCwd(cwd string) {
+	for _, t := range t.List {
+		t.BindCwd(cwd)
+	}
+}
+func (t TriggerAny) Next(after time.Time) (next time.Time) {
+	for _, t := range t.List {
+		if s, ok := t.ITrigger.(ScheduledTrigger); ok {
+			if n := s.Next(after); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+				next = n
+			}
+		}
+	}
+	return
+}
+
+func (t *Trigger) BindCwd(cwd string) {
+	if t == nil {
+		return
+	}
+	if b, ok := t.ITrigger.(CwdTrigger); ok {
+		b.BindCwd(cwd)
+	}
+}
+
 func init() {
 
 	TriggerRegistry.Define("any", TriggerAny{})
@@ -45,7 +81,7 @@ func init() {
 			return err
 		}
 		w.ITrigger = t
-		w.Kind = "many"
+		w.Kind = "any"
 		return nil
 	})
 }
@@ -1,9 +1,12 @@
 package job
 
 import (
+	"errors"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/can1357/gosu/pkg/automarshal"
 	"github.com/can1357/gosu/pkg/util"
 )
 
@@ -29,6 +32,72 @@ func (t TriggerEvery) Listen(callback func()) (remove func()) {
 func (h *TriggerEvery) UnmarshalInline(text string) (err error) {
 	return h.Duration.UnmarshalText([]byte(text))
 }
+
+// Maximum time to sleep before re-checking the wall clock, so that suspends and clock changes are noticed.
+const cronMaxSleep = time.Minute
+
+type TriggerCron struct {
+	Schedule util.CronSchedule `json:"schedule"`
+	Timezone string            `json:"tz,omitempty"` // Overrides the timezone of the schedule.
+	inline   string
+}
+
+func (t TriggerCron) Next(after time.Time) time.Time {
+	return t.Schedule.Next(after)
+}
+func (t TriggerCron) Listen(callback func()) (remove func()) {
+	done := make(chan struct{})
+	go func() {
+		next := t.Next(time.Now())
+		for !next.IsZero() {
+			wait := time.Until(next)
+			if wait > 0 {
+				select {
+				case <-done:
+					return
+				case <-time.After(min(wait, cronMaxSleep)):
+					continue
+				}
+			}
+			go callback()
+			next = t.Next(time.Now())
+		}
+	}()
+	return func() {
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+	}
+}
+func (h *TriggerCron) UnmarshalInline(text string) (err error) {
+	h.inline = text
+	return
+}
+func (h *TriggerCron) WithDefaults(i automarshal.ID) {
+	// "cron:0 3 * * *" is split into the ID "0" and the inline text "3 * * *", stitch it back.
+	if h.Schedule.IsZero() {
+		h.inline = strings.TrimSpace(i.ID + " " + h.inline)
+	}
+}
+func (h *TriggerCron) Validate() (err error) {
+	if h.inline != "" {
+		if err = h.Schedule.UnmarshalText([]byte(h.inline)); err != nil {
+			return
+		}
+		h.inline = ""
+	}
+	if h.Schedule.IsZero() {
+		return errors.New("cron trigger requires a schedule")
+	}
+	if h.Timezone != "" {
+		h.Schedule.Location, err = time.LoadLocation(h.Timezone)
+	}
+	return
+}
+
 func init() {
 	TriggerRegistry.Define("every", TriggerEvery{})
+	TriggerRegistry.Define("cron", TriggerCron{})
 }
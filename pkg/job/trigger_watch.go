@@ -0,0 +1,175 @@
+package job
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/can1357/gosu/pkg/automarshal"
+	"github.com/can1357/gosu/pkg/util"
+	"github.com/samber/lo"
+)
+
+var defaultWatchExclude = []string{"node_modules", ".git"}
+
+type TriggerWatch struct {
+	Root     string                `json:"root,omitempty"`     // The directory to watch, relative to the job's working directory.
+	Include  []string              `json:"include,omitempty"`  // Globs of files to watch, empty means all.
+	Exclude  []string              `json:"exclude,omitempty"`  // Globs of files to ignore, node_modules and .git are always ignored.
+	Debounce util.ParsableDuration `json:"debounce,omitempty"` // The quiet period to wait for after a change before firing.
+	cwd      string
+}
+
+// Matches a slash separated path against a glob, where "**" matches any number of segments.
+// Globs without a slash are matched against every segment of the path instead.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		for _, seg := range strings.Split(name, "/") {
+			if ok, _ := path.Match(pattern, seg); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func (t *TriggerWatch) BindCwd(cwd string) {
+	t.cwd = cwd
+}
+func (t TriggerWatch) root() string {
+	if filepath.IsAbs(t.Root) {
+		return t.Root
+	}
+	return filepath.Join(t.cwd, t.Root)
+}
+func (t TriggerWatch) excluded(rel string) bool {
+	for _, pattern := range t.Exclude {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+func (t TriggerWatch) included(rel string) bool {
+	if t.excluded(rel) {
+		return false
+	}
+	if len(t.Include) == 0 {
+		return true
+	}
+	for _, pattern := range t.Include {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+func (t TriggerWatch) relative(root, name string) (string, bool) {
+	rel, err := filepath.Rel(root, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Walks the tree under root, calling fn for every directory that is not excluded.
+func (t TriggerWatch) walkDirs(root string, fn func(dir string)) {
+	filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if rel, ok := t.relative(t.root(), name); ok && rel != "." && t.excluded(rel) {
+			return filepath.SkipDir
+		}
+		fn(name)
+		return nil
+	})
+}
+
+func (t TriggerWatch) Listen(callback func()) (remove func()) {
+	var mu sync.Mutex
+	var timer *time.Timer
+	root := t.root()
+	onChange := func(name string) {
+		if rel, ok := t.relative(root, name); !ok || !t.included(rel) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if timer == nil {
+			timer = time.AfterFunc(t.Debounce.Duration, func() {
+				fmt.Printf("Change detected in %s\n", root)
+				callback()
+			})
+		} else {
+			timer.Reset(t.Debounce.Duration)
+		}
+	}
+
+	stop, err := t.watch(root, onChange)
+	if err != nil {
+		fmt.Printf("Failed to watch %s: %v\n", root, err)
+		return func() {}
+	}
+	return func() {
+		stop()
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (h *TriggerWatch) UnmarshalInline(text string) (err error) {
+	for _, pattern := range strings.Fields(text) {
+		if after, found := strings.CutPrefix(pattern, "!"); found {
+			h.Exclude = append(h.Exclude, after)
+		} else {
+			h.Include = append(h.Include, pattern)
+		}
+	}
+	return
+}
+func (h *TriggerWatch) WithDefaults(i automarshal.ID) {
+	if i.ID != "" && len(h.Include) == 0 {
+		h.Include = []string{i.ID}
+	}
+	for _, pattern := range defaultWatchExclude {
+		if !lo.Contains(h.Exclude, pattern) {
+			h.Exclude = append(h.Exclude, pattern)
+		}
+	}
+	if !h.Debounce.IsPositive() {
+		h.Debounce = util.Duration(300 * time.Millisecond)
+	}
+}
+
+func init() {
+	TriggerRegistry.Define("watch", TriggerWatch{})
+}
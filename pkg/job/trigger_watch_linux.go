@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB
+
+func (t TriggerWatch) watch(root string, onChange func(name string)) (stop func(), err error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	var mu sync.Mutex
+	dirs := map[int]string{}
+	addTree := func(dir string) {
+		t.walkDirs(dir, func(dir string) {
+			wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask|unix.IN_ONLYDIR)
+			if err == nil {
+				mu.Lock()
+				dirs[wd] = dir
+				mu.Unlock()
+			}
+		})
+	}
+	if _, err = os.Stat(root); err != nil {
+		file.Close()
+		return nil, err
+	}
+	addTree(root)
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				mu.Lock()
+				dir, ok := dirs[int(event.Wd)]
+				if event.Mask&unix.IN_IGNORED != 0 {
+					delete(dirs, int(event.Wd))
+				}
+				mu.Unlock()
+				if !ok {
+					continue
+				}
+				name := dir
+				if i := indexNull(nameBytes); i > 0 {
+					name = filepath.Join(dir, string(nameBytes[:i]))
+				}
+				if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					addTree(name)
+				}
+				onChange(name)
+			}
+		}
+	}()
+	return func() { file.Close() }, nil
+}
+
+func indexNull(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,59 @@
+//go:build !linux
+// +build !linux
+
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Without inotify, fall back to polling modification times.
+const watchPollRate = time.Second
+
+func (t TriggerWatch) snapshot(root string) map[string]time.Time {
+	files := map[string]time.Time{}
+	t.walkDirs(root, func(dir string) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && !entry.IsDir() {
+				files[filepath.Join(dir, entry.Name())] = info.ModTime()
+			}
+		}
+	})
+	return files
+}
+
+func (t TriggerWatch) watch(root string, onChange func(name string)) (stop func(), err error) {
+	if _, err = os.Stat(root); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	prev := t.snapshot(root)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(watchPollRate):
+			}
+			next := t.snapshot(root)
+			for name, mtime := range next {
+				if before, ok := prev[name]; !ok || !before.Equal(mtime) {
+					onChange(name)
+				}
+			}
+			for name := range prev {
+				if _, ok := next[name]; !ok {
+					onChange(name)
+				}
+			}
+			prev = next
+		}
+	}()
+	return func() { close(done) }, nil
+}
@@ -44,10 +44,31 @@ type ClientSession struct {
 
 type LoadBalancer struct {
 	Options
-	Upstreams []*Upstream
-	mu        sync.RWMutex
-	server    *http.Server
-	sessions  sync.Map //map[string]*ClientSession
+	Name       string // The name reported in the metrics, the namespace of the task serving it.
+	Upstreams  []*Upstream
+	mu         sync.RWMutex
+	server     *http.Server
+	sessions   sync.Map //map[string]*ClientSession
+	requests   atomic.Int64
+	retries    atomic.Int64
+	badGateway atomic.Int64
+}
+
+// Counters of a load balancer.
+type Stats struct {
+	Requests    int64          `json:"requests"`    // Requests received, retries excluded.
+	Retries     int64          `json:"retries"`     // Requests retried on another upstream.
+	BadGateway  int64          `json:"bad_gateway"` // Requests answered with a 502.
+	Connections map[string]int `json:"connections"` // Open connections by upstream.
+}
+
+var balancers sync.Map // *LoadBalancer -> struct{}
+
+// Calls the function for each listening load balancer.
+func Range(fn func(lb *LoadBalancer) bool) {
+	balancers.Range(func(key, _ any) bool {
+		return fn(key.(*LoadBalancer))
+	})
 }
 
 func NewLoadBalancer(opt Options) (lb *LoadBalancer) {
@@ -56,6 +77,19 @@ func NewLoadBalancer(opt Options) (lb *LoadBalancer) {
 	return
 }
 
+func (lb *LoadBalancer) Stats() (s Stats) {
+	s.Requests = lb.requests.Load()
+	s.Retries = lb.retries.Load()
+	s.BadGateway = lb.badGateway.Load()
+	s.Connections = map[string]int{}
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	for _, u := range lb.Upstreams {
+		s.Connections[u.Name] = u.Connections()
+	}
+	return
+}
+
 func (lb *LoadBalancer) AddUpstream(u *Upstream) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
@@ -168,10 +202,13 @@ func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx = v.(*requestContext)
 		if ctx.RetryCount >= lb.RetryMax {
 			clog.FromContext(r.Context()).Printf("retry count exceeded")
+			lb.badGateway.Add(1)
 			http.Error(w, "", http.StatusBadGateway)
 			return
 		}
+		lb.retries.Add(1)
 	} else {
+		lb.requests.Add(1)
 		ctx = &requestContext{Ip: getClientIp(r), Lb: lb, Previous: nil}
 		r = r.WithContext(context.WithValue(r.Context(), requestContextKey{}, ctx))
 	}
@@ -187,12 +224,16 @@ func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx.Previous = us
 		us.ServeHTTP(w, r)
 	} else {
+		lb.badGateway.Add(1)
 		http.Error(w, "", http.StatusBadGateway)
 	}
 }
 func (lb *LoadBalancer) Listen() error {
+	balancers.Store(lb, struct{}{})
+	defer balancers.Delete(lb)
 	return lb.server.ListenAndServe()
 }
 func (lb *LoadBalancer) Close() {
+	balancers.Delete(lb)
 	lb.server.Close()
 }
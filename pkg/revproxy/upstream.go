@@ -50,6 +50,7 @@ func NewUpstream(name string, dialer Dialer) (u *Upstream) {
 					ctx.Lb.ServeHTTP(w, r)
 					return
 				}
+				ctx.Lb.badGateway.Add(1)
 			}
 			http.Error(w, "", http.StatusBadGateway)
 		},
@@ -62,6 +63,10 @@ func NewIpcUpstream(name string, address string) (u *Upstream) {
 	})
 }
 
+func (p *Upstream) Connections() int {
+	return int(p.numConnections.Load())
+}
+
 func (p *Upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.numConnections.Add(1)
 	defer p.numConnections.Add(-1)
@@ -0,0 +1,257 @@
+package session
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/can1357/gosu/pkg/clog"
+)
+
+const (
+	errorIdle      = time.Second // A trace is complete once no line arrived for this long.
+	errorMaxLines  = 200         // Traces are cut after this many lines.
+	errorMaxSample = 8 * 1024    // Maximum size of the stored sample trace.
+	errorFrames    = 5           // Number of frames that make up the fingerprint.
+)
+
+// A group of occurrences of the same error, persisted across daemon restarts.
+type ErrorGroup struct {
+	Job         string    `json:"job"`
+	Fingerprint string    `json:"fingerprint"`
+	Namespace   string    `json:"namespace"` // The namespace of the last occurrence.
+	Type        string    `json:"type"`      // The exception type such as TypeError or panic.
+	Message     string    `json:"message"`   // The message of the last occurrence.
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Sample      string    `json:"sample"` // The trace of the last occurrence.
+}
+
+type traceKind uint8
+
+const (
+	traceFrames traceKind = iota // Node and Java, a header followed by "at" frames.
+	tracePython
+	traceGo
+)
+
+var (
+	rgxFrame     = regexp.MustCompile(`^\s+at \S`)
+	rgxGoPanic   = regexp.MustCompile(`^(panic: |fatal error: )`)
+	rgxJavaTitle = regexp.MustCompile(`^Exception in thread "[^"]*" `)
+	rgxErrorType = regexp.MustCompile(`^[\w$.]+$`)
+	rgxHex       = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	rgxDigits    = regexp.MustCompile(`\d+`)
+)
+
+type pendingTrace struct {
+	kind      traceKind
+	job       string
+	ns        string
+	lines     []string
+	goroutine bool      // Go traces only, the goroutine section has started.
+	last      time.Time // The time the last line arrived.
+}
+
+// Whether the line is part of the trace.
+func (p *pendingTrace) continues(line string) bool {
+	indented := line != "" && (line[0] == ' ' || line[0] == '\t')
+	switch p.kind {
+	case traceFrames:
+		return indented || strings.HasPrefix(line, "Caused by:") || line == "}"
+	case tracePython:
+		return indented
+	default:
+		if !p.goroutine || line == "" || indented {
+			return true
+		}
+		return strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "created by ") || strings.HasSuffix(line, ")")
+	}
+}
+
+// Splits the trace into its exception type, message and the frames identifying it.
+func (p *pendingTrace) describe() (typ, msg string, frames []string) {
+	header := p.lines[0]
+	switch p.kind {
+	case tracePython:
+		header = p.lines[len(p.lines)-1]
+		for _, line := range p.lines {
+			if strings.HasPrefix(strings.TrimSpace(line), "File ") {
+				frames = append(frames, line)
+			}
+		}
+		// The innermost frames are last.
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+	case traceGo:
+		for _, line := range p.lines[1:] {
+			if line != "" && line[0] != '\t' && strings.HasSuffix(line, ")") {
+				frames = append(frames, line)
+			}
+		}
+	default:
+		header = rgxJavaTitle.ReplaceAllString(strings.TrimPrefix(header, "Uncaught "), "")
+		for _, line := range p.lines[1:] {
+			if rgxFrame.MatchString(line) {
+				frames = append(frames, line)
+			}
+		}
+	}
+	typ, msg, found := strings.Cut(header, ": ")
+	if p.kind != traceGo && (!found || !rgxErrorType.MatchString(typ)) {
+		typ, msg = "Error", header
+	}
+	return typ, strings.TrimSpace(msg), frames[:min(len(frames), errorFrames)]
+}
+
+func normalizeTrace(s string) string {
+	return rgxDigits.ReplaceAllString(rgxHex.ReplaceAllString(strings.TrimSpace(s), "0x"), "")
+}
+
+// Fingerprints the trace by its type and innermost frames, or its message if it has none.
+func fingerprint(job, typ, msg string, frames []string) string {
+	h := sha1.New()
+	h.Write([]byte(job + "\x00" + typ + "\x00"))
+	if len(frames) == 0 {
+		h.Write([]byte(normalizeTrace(msg)))
+	}
+	for _, f := range frames {
+		h.Write([]byte(normalizeTrace(f) + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Watches the stderr of the jobs for exceptions and groups them.
+type errorTracker struct {
+	session *Session
+	ch      chan *clog.Entry
+	pending map[string]*pendingTrace // Traces being read by namespace.
+	prev    map[string]string        // The last line by namespace, the header of Node and Java traces.
+}
+
+func newErrorTracker(s *Session) *errorTracker {
+	return &errorTracker{
+		session: s,
+		ch:      make(chan *clog.Entry, 1024),
+		pending: map[string]*pendingTrace{},
+		prev:    map[string]string{},
+	}
+}
+
+// Implements clog.Hook, never blocks the writer.
+func (t *errorTracker) Write(line string, entry *clog.Entry) {
+	if entry.Stream != clog.StreamStderr || entry.Job == "" {
+		return
+	}
+	select {
+	case t.ch <- entry:
+	default:
+	}
+}
+
+func (t *errorTracker) feed(job, ns, line string, now time.Time) {
+	if p := t.pending[ns]; p != nil {
+		if p.continues(line) {
+			if len(p.lines) < errorMaxLines {
+				p.lines = append(p.lines, line)
+			}
+			p.last = now
+			if p.kind == traceGo && strings.HasPrefix(line, "goroutine ") {
+				p.goroutine = true
+			}
+			return
+		}
+		// Python traces end with the exception line.
+		if p.kind == tracePython {
+			p.lines = append(p.lines, line)
+			t.finish(p)
+			return
+		}
+		t.finish(p)
+	}
+
+	switch {
+	case strings.HasPrefix(line, "Traceback (most recent call last):"):
+		t.pending[ns] = &pendingTrace{kind: tracePython, job: job, ns: ns, lines: []string{line}, last: now}
+	case rgxGoPanic.MatchString(line):
+		t.pending[ns] = &pendingTrace{kind: traceGo, job: job, ns: ns, lines: []string{line}, last: now}
+	case rgxFrame.MatchString(line) && strings.TrimSpace(t.prev[ns]) != "":
+		t.pending[ns] = &pendingTrace{kind: traceFrames, job: job, ns: ns, lines: []string{t.prev[ns], line}, last: now}
+	}
+	t.prev[ns] = line
+}
+
+func (t *errorTracker) finish(p *pendingTrace) {
+	delete(t.pending, p.ns)
+	delete(t.prev, p.ns)
+	typ, msg, frames := p.describe()
+	sample := strings.Join(p.lines, "\n")
+	if len(sample) > errorMaxSample {
+		sample = sample[:errorMaxSample]
+	}
+	t.session.recordError(ErrorGroup{
+		Job:         p.job,
+		Fingerprint: fingerprint(p.job, typ, msg, frames),
+		Namespace:   p.ns,
+		Type:        typ,
+		Message:     msg,
+		Count:       1,
+		FirstSeen:   p.last,
+		LastSeen:    p.last,
+		Sample:      sample,
+	})
+}
+
+func (t *errorTracker) run() {
+	ticker := time.NewTicker(errorIdle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.session.ctx.Done():
+			return
+		case e := <-t.ch:
+			for _, line := range strings.Split(e.Message, "\n") {
+				t.feed(e.Job, e.Namespace, strings.TrimRight(line, "\r"), e.Time)
+			}
+		case now := <-ticker.C:
+			for _, p := range t.pending {
+				if now.Sub(p.last) >= errorIdle {
+					t.finish(p)
+				}
+			}
+		}
+	}
+}
+
+func (s *Session) recordError(e ErrorGroup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.ErrorCollection.Upsert(e.Job+"/"+e.Fingerprint, func(prev *ErrorGroup) ErrorGroup {
+		if prev != nil {
+			e.Count = prev.Count + 1
+			e.FirstSeen = prev.FirstSeen
+		}
+		return e
+	})
+}
+
+// Removes the error groups of the job.
+func (s *Session) clearErrors(id string) {
+	var keys []string
+	s.ErrorCollection.Range(func(key string, e ErrorGroup) bool {
+		if e.Job == id {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	for _, key := range keys {
+		s.ErrorCollection.Delete(key)
+	}
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"context"
+
+	"github.com/can1357/gosu/pkg/task"
+)
+
+const historyLimit = 100
+
+// Crash history of a job, persisted across daemon restarts.
+type JobHistory struct {
+	Restarts map[string]int `json:"restarts"` // Number of restarts per namespace.
+	Exits    []task.Exit    `json:"exits"`    // The most recent exits, oldest first.
+}
+
+// Returns the last exit of the given namespace, if any.
+func (h JobHistory) LastExit(ns string) *task.Exit {
+	for i := len(h.Exits) - 1; i >= 0; i-- {
+		if h.Exits[i].Namespace == ns {
+			return &h.Exits[i]
+		}
+	}
+	return nil
+}
+
+func (s *Session) recordExit(id string, e task.Exit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.HistoryCollection.Upsert(id, func(prev *JobHistory) (h JobHistory) {
+		if prev != nil {
+			h = *prev
+		}
+		if h.Restarts == nil {
+			h.Restarts = map[string]int{}
+		}
+		if e.Restarted {
+			h.Restarts[e.Namespace]++
+		}
+		h.Exits = append(h.Exits, e)
+		if len(h.Exits) > historyLimit {
+			h.Exits = h.Exits[len(h.Exits)-historyLimit:]
+		}
+		return
+	})
+}
+
+// Returns the context the job runs in, recording the exits of its tasks.
+func (s *Session) jobContext(id string) context.Context {
+	return task.ExitHook(func(e task.Exit) {
+		s.recordExit(id, e)
+	}).WithContext(s.ctx)
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/can1357/gosu/pkg/job"
+	"github.com/can1357/gosu/pkg/revproxy"
+	"github.com/can1357/gosu/pkg/task"
+	"github.com/samber/lo"
+)
+
+// A metric family in the Prometheus text format.
+type metricFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []string
+}
+
+func (f *metricFamily) add(labels []string, value float64) {
+	b := strings.Builder{}
+	b.WriteString(f.name)
+	b.WriteString("{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i != 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("} ")
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	f.samples = append(f.samples, b.String())
+}
+
+func (f *metricFamily) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+	for _, s := range f.samples {
+		if err != nil {
+			break
+		}
+		var m int
+		m, err = fmt.Fprintln(w, s)
+		n += m
+	}
+	return int64(n), err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var rgxLabelName = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Labels identifying the job, followed by the labels of its manifest. Labels whose sanitized
+// name collides with an earlier one are dropped so that every series has distinct label names.
+func jobLabels(j *job.Job) []string {
+	labels := []string{"id", j.ID}
+	seen := map[string]bool{}
+	keys := make([]string, 0, len(j.Manifest.Labels))
+	for k := range j.Manifest.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := rgxLabelName.ReplaceAllString(k, "_")
+		switch {
+		case name == "" || name == "id" || name == "namespace" || name == "status" || name == "proxy" || name == "upstream" || strings.HasPrefix(name, "__"):
+			continue
+		case name[0] >= '0' && name[0] <= '9':
+			name = "_" + name
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		labels = append(labels, name, j.Manifest.Labels[k])
+	}
+	return labels
+}
+
+func withLabels(labels []string, extra ...string) []string {
+	return append(append([]string{}, labels...), extra...)
+}
+
+// Serves the state of the jobs and their proxies in the Prometheus text format.
+func (s *Session) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.RpcServer.AuthorizeToken(w, r) {
+		return
+	}
+
+	status := &metricFamily{name: "gosu_task_status", kind: "gauge", help: "Status of the task, 1 for the current one."}
+	up := &metricFamily{name: "gosu_task_up", kind: "gauge", help: "Whether the task is alive."}
+	restarts := &metricFamily{name: "gosu_task_restarts_total", kind: "counter", help: "Number of restarts of the task."}
+	cpu := &metricFamily{name: "gosu_task_cpu_percent", kind: "gauge", help: "CPU usage of the processes of the task."}
+	rss := &metricFamily{name: "gosu_task_memory_rss_bytes", kind: "gauge", help: "Resident memory of the processes of the task."}
+	uptime := &metricFamily{name: "gosu_task_uptime_seconds", kind: "gauge", help: "Time since the process of the task started."}
+	requests := &metricFamily{name: "gosu_proxy_requests_total", kind: "counter", help: "Requests received by the proxy, retries excluded."}
+	retries := &metricFamily{name: "gosu_proxy_retries_total", kind: "counter", help: "Requests the proxy retried on another upstream."}
+	badGateway := &metricFamily{name: "gosu_proxy_bad_gateway_total", kind: "counter", help: "Requests the proxy answered with a 502."}
+	connections := &metricFamily{name: "gosu_proxy_upstream_connections", kind: "gauge", help: "Open connections to the upstream."}
+
+	var visit func(w task.Worker, labels []string, h JobHistory)
+	visit = func(w task.Worker, labels []string, h JobHistory) {
+		ns := w.Namespace()
+		labels = withLabels(labels, "namespace", ns)
+		st := task.StatusFromErr(w.Status())
+		report := w.Inspect()
+		status.add(withLabels(labels, "status", st.String()), 1)
+		up.add(labels, lo.Ternary(st.IsAlive(), 1.0, 0.0))
+		restarts.add(labels, float64(h.Restarts[ns]))
+		cpu.add(labels, report.Cpu)
+		rss.add(labels, report.Mem)
+		if !report.IsZero() && !report.CreateTime.IsZero() {
+			uptime.add(labels, time.Since(report.CreateTime).Seconds())
+		} else {
+			uptime.add(labels, 0)
+		}
+		w.Traverse(func(child task.Worker) bool {
+			visit(child, labels[:len(labels)-2], h)
+			return true
+		})
+	}
+	s.ForEachJob("", func(j *job.Job) error {
+		labels := jobLabels(j)
+		h, _ := s.HistoryCollection.Get(j.ID)
+		if w := j.Worker(); w != nil {
+			visit(w, labels, h)
+		} else {
+			labels = withLabels(labels, "namespace", j.ID)
+			status.add(withLabels(labels, "status", task.Idle.String()), 1)
+			up.add(labels, 0)
+			restarts.add(labels, float64(h.Restarts[j.ID]))
+		}
+		return nil
+	})
+
+	revproxy.Range(func(lb *revproxy.LoadBalancer) bool {
+		labels := []string{"id", lb.Name}
+		id, _, _ := strings.Cut(lb.Name, "/")
+		if j, ok := s.Jobs.Load(id); ok {
+			labels = jobLabels(j.(*job.Job))
+		}
+		labels = withLabels(labels, "proxy", lb.Options.Listen)
+		stats := lb.Stats()
+		requests.add(labels, float64(stats.Requests))
+		retries.add(labels, float64(stats.Retries))
+		badGateway.add(labels, float64(stats.BadGateway))
+		for upstream, n := range stats.Connections {
+			connections.add(withLabels(labels, "upstream", upstream), float64(n))
+		}
+		return true
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, f := range []*metricFamily{status, up, restarts, cpu, rss, uptime, requests, retries, badGateway, connections} {
+		if _, err := f.WriteTo(w); err != nil {
+			return
+		}
+	}
+}
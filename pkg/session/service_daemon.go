@@ -1,13 +1,11 @@
 package session
 
-import "time"
-
 type DaemonService struct {
 	session *Session
 }
 
 func (s *DaemonService) Shutdown(_ *any, _ *any) error {
-	s.session.StopAll(5 * time.Second)
+	s.session.StopAll()
 	s.session.Close(nil)
 	return nil
 }
@@ -0,0 +1,32 @@
+package session
+
+import (
+	"sort"
+
+	"github.com/can1357/gosu/pkg/job"
+)
+
+type ErrorService struct {
+	session *Session
+}
+
+func (s *ErrorService) List(match *string, result *[]ErrorGroup) error {
+	jobs := map[string]bool{}
+	err := s.session.ForEachJob(*match, func(j *job.Job) error {
+		jobs[j.ID] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.session.ErrorCollection.Range(func(key string, e ErrorGroup) bool {
+		if jobs[e.Job] {
+			*result = append(*result, e)
+		}
+		return true
+	})
+	sort.SliceStable(*result, func(i, j int) bool {
+		return (*result)[i].LastSeen.Before((*result)[j].LastSeen)
+	})
+	return nil
+}
@@ -1,8 +1,17 @@
 package session
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/can1357/gosu/pkg/clog"
 	"github.com/can1357/gosu/pkg/job"
 	"github.com/can1357/gosu/pkg/task"
+	"github.com/can1357/gosu/pkg/util"
 )
 
 type RpcStatus struct {
@@ -14,11 +23,44 @@ type RpcTaskInfo struct {
 	Namespace string        `json:"namespace"`
 	Status    RpcStatus     `json:"status"`
 	Report    task.Report   `json:"report,omitempty"`
+	Restarts  int           `json:"restarts"`            // Number of restarts, persisted across daemon restarts.
+	LastExit  *task.Exit    `json:"last_exit,omitempty"` // The last crash of the task, if any.
 	Children  []RpcTaskInfo `json:"children,omitempty"`
 }
 type RpcJobInfo struct {
 	ID   string      `json:"id"`
 	Main RpcTaskInfo `json:"main"`
+	Next *time.Time  `json:"next,omitempty"` // Next scheduled launch, if any.
+}
+type RpcSignal struct {
+	Match  string `json:"match"`
+	Signal string `json:"signal"`
+}
+type RpcExit struct {
+	Job string `json:"job"`
+	task.Exit
+}
+type RpcMetricsQuery struct {
+	Match string    `json:"match"`
+	Since time.Time `json:"since"` // Windows of up to 5 minutes are sampled every second, longer ones every minute.
+}
+type RpcTaskMetrics struct {
+	Job       string              `json:"job"`
+	Namespace string              `json:"namespace"`
+	Samples   []task.MetricSample `json:"samples"`
+}
+type RpcLogFile struct {
+	Stream  string   `json:"stream"`
+	Path    string   `json:"path"`
+	Size    int64    `json:"size"`
+	Rotated []string `json:"rotated,omitempty"` // The rotated files, oldest first.
+}
+type RpcJobDescription struct {
+	RpcJobInfo
+	Manifest   job.Manifest               `json:"manifest"`             // The manifest with the defaults filled in.
+	Options    task.Options               `json:"options"`              // The task options in effect.
+	Logs       []RpcLogFile               `json:"logs"`                 //
+	Whiteboard map[string]json.RawMessage `json:"whiteboard,omitempty"` //
 }
 type RpcSessionJobs struct {
 	Jobs []RpcJobInfo `json:"jobs"`
@@ -37,30 +79,111 @@ type JobService struct {
 	session *Session
 }
 
-func (s *JobService) taskInfo(w task.Worker) (t RpcTaskInfo) {
+func (s *JobService) taskInfo(w task.Worker, h JobHistory) (t RpcTaskInfo) {
 	if w == nil {
 		return
 	}
 	t.Namespace = w.Namespace()
 	t.Status = makeRpcStatus(w.Status())
 	t.Report = w.Inspect()
+	t.Restarts = h.Restarts[t.Namespace]
+	t.LastExit = h.LastExit(t.Namespace)
 	w.Traverse(func(child task.Worker) bool {
-		t.Children = append(t.Children, s.taskInfo(child))
+		t.Children = append(t.Children, s.taskInfo(child, h))
 		return true
 	})
 	return
 }
+
+// Fills in the listening ports of the task and its children.
+func withPorts(t *RpcTaskInfo) {
+	t.Report.Ports = task.ListeningPorts(t.Report.Pid)
+	for i := range t.Children {
+		withPorts(&t.Children[i])
+	}
+}
 func (s *JobService) jobInfo(j *job.Job) (o RpcJobInfo) {
 	o.ID = j.ID
-	o.Main = s.taskInfo(j.Worker())
+	h, _ := s.session.HistoryCollection.Get(j.ID)
+	o.Main = s.taskInfo(j.Worker(), h)
+	if next := j.NextLaunch(); !next.IsZero() {
+		o.Next = &next
+	}
 	return
 }
+func (s *JobService) describe(j *job.Job) (d RpcJobDescription) {
+	d.RpcJobInfo = s.jobInfo(j)
+	withPorts(&d.Main)
+
+	d.Manifest = *j.Manifest
+	d.Manifest.ID = j.ID
+	d.Manifest.Main = j.Main
+	d.Manifest.Build = j.Build
+	if d.Manifest.LogName == "" {
+		d.Manifest.LogName = j.ID
+	}
+
+	d.Options = j.Options
+	d.Options.WithDefaults()
+	if tcfg, ok := j.Main.ITask.(task.TaskWithOpts); ok {
+		tcfg.Configure(&d.Options)
+	}
+
+	paths := j.Logger.Paths
+	for stream, path := range paths {
+		if path == "" || (stream != 0 && path == paths[0]) {
+			continue
+		}
+		f := RpcLogFile{Stream: clog.Stream(stream).String(), Path: path, Rotated: clog.RotatedFiles(path)}
+		if fi, err := os.Stat(path); err == nil {
+			f.Size = fi.Size()
+		}
+		d.Logs = append(d.Logs, f)
+	}
+	if d.Manifest.Output == "" {
+		d.Manifest.Output = paths[clog.StreamStdout]
+	}
+	if d.Manifest.Error == "" {
+		d.Manifest.Error = paths[clog.StreamStderr]
+	}
+
+	if wb := j.Whiteboard.Load(); wb != nil {
+		wb.Range(func(key string, value json.RawMessage) bool {
+			if d.Whiteboard == nil {
+				d.Whiteboard = map[string]json.RawMessage{}
+			}
+			d.Whiteboard[key] = value
+			return true
+		})
+	}
+	return
+}
+func (s *JobService) Describe(match *string, result *[]RpcJobDescription) error {
+	err := s.session.ForEachJob(*match, func(j *job.Job) error {
+		*result = append(*result, s.describe(j))
+		return nil
+	})
+	sort.SliceStable(*result, func(i, j int) bool {
+		return (*result)[i].ID < (*result)[j].ID
+	})
+	return err
+}
 func (s *JobService) List(match *string, result *RpcSessionJobs) error {
 	return s.session.ForEachJob(*match, func(j *job.Job) error {
 		(*result).Jobs = append((*result).Jobs, s.jobInfo(j))
 		return nil
 	})
 }
+
+// Same as List, but also collects the listening ports of the processes.
+func (s *JobService) ListWide(match *string, result *RpcSessionJobs) error {
+	return s.session.ForEachJob(*match, func(j *job.Job) error {
+		info := s.jobInfo(j)
+		withPorts(&info.Main)
+		(*result).Jobs = append((*result).Jobs, info)
+		return nil
+	})
+}
 func (s *JobService) Launch(recipe job.Manifest, result *RpcJobInfo) error {
 	j, err := recipe.Spawn()
 	if err != nil {
@@ -103,7 +226,75 @@ func (s *JobService) Stop(match *string, list *[]string) error {
 }
 func (s *JobService) Restart(match *string, list *[]string) error {
 	return s.session.ForEachJob(*match, func(j *job.Job) error {
-		j.Restart()
+		if err := j.Restart(); err != nil {
+			return fmt.Errorf("failed to restart job %s: %w", j.ID, err)
+		}
+		*list = append(*list, j.ID)
+		return nil
+	})
+}
+func (s *JobService) Reload(match *string, list *[]string) error {
+	return s.session.ForEachJob(*match, func(j *job.Job) error {
+		if err := j.Reload(); errors.Is(err, task.Idle) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to reload job %s: %w", j.ID, err)
+		}
+		*list = append(*list, j.ID)
+		return nil
+	})
+}
+func (s *JobService) Signal(args *RpcSignal, list *[]string) error {
+	sig, err := util.ParseSignal(args.Signal)
+	if err != nil {
+		return err
+	}
+	return s.session.ForEachJob(args.Match, func(j *job.Job) error {
+		if err := j.Signal(sig.Signal); errors.Is(err, task.Idle) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to signal job %s: %w", j.ID, err)
+		}
+		*list = append(*list, j.ID)
+		return nil
+	})
+}
+func (s *JobService) History(match *string, result *[]RpcExit) error {
+	err := s.session.ForEachJob(*match, func(j *job.Job) error {
+		h, _ := s.session.HistoryCollection.Get(j.ID)
+		for _, e := range h.Exits {
+			*result = append(*result, RpcExit{Job: j.ID, Exit: e})
+		}
+		return nil
+	})
+	sort.SliceStable(*result, func(i, j int) bool {
+		return (*result)[i].Time.Before((*result)[j].Time)
+	})
+	return err
+}
+func (s *JobService) Metrics(args *RpcMetricsQuery, result *[]RpcTaskMetrics) error {
+	var visit func(id string, w task.Worker)
+	visit = func(id string, w task.Worker) {
+		if samples := w.Metrics().Since(args.Since); len(samples) != 0 {
+			*result = append(*result, RpcTaskMetrics{Job: id, Namespace: w.Namespace(), Samples: samples})
+		}
+		w.Traverse(func(child task.Worker) bool {
+			visit(id, child)
+			return true
+		})
+	}
+	return s.session.ForEachJob(args.Match, func(j *job.Job) error {
+		if w := j.Worker(); w != nil {
+			visit(j.ID, w)
+		}
+		return nil
+	})
+}
+func (s *JobService) RotateLogs(match *string, list *[]string) error {
+	return s.session.ForEachJob(*match, func(j *job.Job) error {
+		if err := j.Logger.Rotate(); err != nil {
+			return fmt.Errorf("failed to rotate logs of job %s: %w", j.ID, err)
+		}
 		*list = append(*list, j.ID)
 		return nil
 	})
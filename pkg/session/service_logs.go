@@ -0,0 +1,60 @@
+package session
+
+import (
+	"time"
+
+	"github.com/can1357/gosu/pkg/clog"
+	"github.com/can1357/gosu/pkg/job"
+)
+
+type RpcLogSearch struct {
+	Match  string    `json:"match"`            // The jobs to search.
+	Query  string    `json:"query"`            // The text to look for.
+	Regex  bool      `json:"regex,omitempty"`  // Interprets the query as a regular expression.
+	Since  time.Time `json:"since"`            // Lower bound of the window, zero for none.
+	Until  time.Time `json:"until"`            // Upper bound of the window, zero for none.
+	Stream string    `json:"stream,omitempty"` // The stream to search, all if empty.
+	Limit  int       `json:"limit,omitempty"`  // Maximum number of results.
+}
+
+type LogService struct {
+	session *Session
+}
+
+func (s *LogService) Search(args *RpcLogSearch, result *[]RpcLogMessage) error {
+	q := clog.SearchQuery{
+		Text:   args.Query,
+		Regex:  args.Regex,
+		Since:  args.Since,
+		Until:  args.Until,
+		Stream: clog.StreamMax,
+		Limit:  args.Limit,
+	}
+	if args.Stream != "" {
+		if err := q.Stream.UnmarshalText([]byte(args.Stream)); err != nil {
+			return err
+		}
+	}
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+
+	var found []clog.SearchResult
+	err := s.session.ForEachJob(args.Match, func(j *job.Job) error {
+		res, err := j.Logger.Search(q)
+		found = append(found, res...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	clog.SortResults(found)
+	if len(found) > q.Limit {
+		found = found[len(found)-q.Limit:]
+	}
+	for i := range found {
+		r := &found[i]
+		*result = append(*result, RpcLogMessage{Kind: r.Entry.Stream.String(), Line: r.Line, Entry: &r.Entry})
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/can1357/gosu/pkg/job"
+)
+
+type RpcProject struct {
+	Name  string         `json:"name"`  // The project owning the jobs.
+	Jobs  []job.Manifest `json:"jobs"`  // Every job of the project, the others it owns are deleted.
+	Force bool           `json:"force"` // Adopts the existing jobs owned by another project or by none.
+}
+type RpcProjectChanges struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`   // Jobs whose manifest changed, restarted.
+	Unchanged []string `json:"unchanged"` // Jobs left as they are, started if they were not running.
+	Deleted   []string `json:"deleted"`   // Jobs the project no longer declares.
+}
+
+type ProjectService struct {
+	session *Session
+	mu      sync.Mutex
+}
+
+// Returns the jobs owned by the project.
+func (s *ProjectService) owned(name string) (ids []string) {
+	s.session.JobCollection.Range(func(id string, mf job.Manifest) bool {
+		if mf.Project == name {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	sort.Strings(ids)
+	return
+}
+
+// Creates or updates the jobs of the project and deletes the ones it no longer declares. Jobs
+// are only restarted if their manifest changed, jobs of the same id that the project does not
+// own are left alone unless forced.
+func (s *ProjectService) Up(p RpcProject, result *RpcProjectChanges) error {
+	if p.Name == "" {
+		return errors.New("missing project name")
+	}
+	seen := map[string]bool{}
+	for _, mf := range p.Jobs {
+		if mf.ID == "" {
+			return errors.New("job without an id")
+		} else if seen[mf.ID] {
+			return fmt.Errorf("duplicate job: %s", mf.ID)
+		}
+		seen[mf.ID] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !p.Force {
+		for _, mf := range p.Jobs {
+			prev, ok := s.session.JobCollection.Get(mf.ID)
+			switch {
+			case !ok || prev.Project == p.Name:
+			case prev.Project == "":
+				return fmt.Errorf("job %s was not created by a project, pass --force to adopt it", mf.ID)
+			default:
+				return fmt.Errorf("job %s belongs to project %s, pass --force to adopt it", mf.ID, prev.Project)
+			}
+		}
+	}
+	*result = RpcProjectChanges{Created: []string{}, Updated: []string{}, Unchanged: []string{}, Deleted: []string{}}
+
+	// Spawn every job first so that an invalid manifest leaves the project untouched.
+	var changed []*job.Job
+	for _, mf := range p.Jobs {
+		mf := mf // The job keeps a pointer to its manifest.
+		mf.Project = p.Name
+		next, _ := json.Marshal(mf)
+		if prev, ok := s.session.JobCollection.Get(mf.ID); ok {
+			if cur, _ := json.Marshal(prev); bytes.Equal(cur, next) {
+				result.Unchanged = append(result.Unchanged, mf.ID)
+				continue
+			}
+			result.Updated = append(result.Updated, mf.ID)
+		} else {
+			result.Created = append(result.Created, mf.ID)
+		}
+		j, err := mf.Spawn()
+		if err != nil {
+			for _, j := range changed {
+				j.Logger.Close()
+			}
+			return fmt.Errorf("%s: %w", mf.ID, err)
+		}
+		changed = append(changed, j)
+	}
+
+	for _, id := range s.owned(p.Name) {
+		if !seen[id] {
+			s.session.DeleteJob(id)
+			result.Deleted = append(result.Deleted, id)
+		}
+	}
+	// Jobs with a launch trigger wait for it.
+	for _, j := range changed {
+		s.session.UpdateJob(j)
+		if j.Launch == nil {
+			j.Start()
+		}
+	}
+	for _, id := range result.Unchanged {
+		if j, ok := s.session.Jobs.Load(id); ok && j.(*job.Job).Launch == nil {
+			j.(*job.Job).Start()
+		}
+	}
+	return nil
+}
+
+// Stops and deletes every job of the project.
+func (s *ProjectService) Down(name *string, list *[]string) error {
+	if *name == "" {
+		return errors.New("missing project name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.owned(*name) {
+		s.session.DeleteJob(id)
+		*list = append(*list, id)
+	}
+	return nil
+}
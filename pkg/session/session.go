@@ -8,29 +8,32 @@ import (
 	"os"
 	"os/signal"
 	"regexp"
-	"strings"
 	"sync"
 	"syscall"
-	"time"
 
+	"github.com/can1357/gosu/pkg/clog"
 	"github.com/can1357/gosu/pkg/job"
 	"github.com/can1357/gosu/pkg/settings"
 	"github.com/can1357/gosu/pkg/surpc"
+	"github.com/can1357/gosu/pkg/task"
 	"github.com/can1357/gosu/pkg/util"
 	"github.com/dgraph-io/badger/v4"
-	"github.com/shirou/gopsutil/v3/process"
 )
 
 type Session struct {
 	Database  *badger.DB
 	RpcServer *surpc.Server
 
-	Jobs          sync.Map
-	JobCollection Collection[string, job.Manifest]
+	Jobs              sync.Map
+	JobCollection     Collection[string, job.Manifest]
+	HistoryCollection Collection[string, JobHistory]
+	ErrorCollection   Collection[string, ErrorGroup]
 
-	mu     sync.Mutex
-	ctx    context.Context
-	cancel context.CancelCauseFunc
+	sinks   []*clog.Sink // Global log sinks.
+	tracker *errorTracker
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
 }
 
 var ErrAlreadyExists = errors.New("job already exists")
@@ -41,21 +44,23 @@ func (s *Session) InsertJob(j *job.Job) error {
 		return ErrAlreadyExists
 	}
 	s.JobCollection.Replace(j.ID, *j.Manifest)
-	j.Ready(s.ctx)
+	j.Ready(s.jobContext(j.ID))
 	return nil
 }
 func (s *Session) UpdateJob(j *job.Job) {
 	if prev, loaded := s.Jobs.Swap(j.ID, j); loaded {
-		prev.(*job.Job).Stop()
+		prev.(*job.Job).Close()
 	}
 	s.JobCollection.Replace(j.ID, *j.Manifest)
-	j.Ready(s.ctx)
+	j.Ready(s.jobContext(j.ID))
 }
 func (s *Session) DeleteJob(id string) error {
 	s.JobCollection.Delete(id)
+	s.HistoryCollection.Delete(id)
+	s.clearErrors(id)
 	val, deleted := s.Jobs.LoadAndDelete(id)
 	if deleted {
-		val.(*job.Job).Stop()
+		val.(*job.Job).Close()
 		return nil
 	} else {
 		return ErrNotFound
@@ -83,6 +88,8 @@ func (s *Session) openDatabase() (err error) {
 		return
 	}
 	s.JobCollection.Open(s.Database, "jobs")
+	s.HistoryCollection.Open(s.Database, "history")
+	s.ErrorCollection.Open(s.Database, "errors")
 	return nil
 }
 func (s *Session) reviveJobs() {
@@ -96,7 +103,7 @@ func (s *Session) reviveJobs() {
 			log.Printf("Error spawning job %s: %v", id, err)
 		} else {
 			s.Jobs.Store(id, j)
-			go j.Ready(s.ctx)
+			go j.Ready(s.jobContext(id))
 		}
 		return true
 	})
@@ -135,24 +142,26 @@ func Open() (s *Session) {
 	}
 
 	// Kill processes that belong to previous session.
-	if processlist, err := process.Processes(); err == nil {
-		for _, proc := range processlist {
-			if env, err := proc.Environ(); err == nil {
-				for _, e := range env {
-					if strings.HasPrefix(e, "GOSU_NS") {
-						if parent, err := proc.Parent(); err == nil {
-							if running, _ := parent.IsRunning(); running {
-								continue
-							}
-						}
-						log.Printf("Killing process %d", proc.Pid)
-						proc.Kill()
-					}
-				}
-			}
+	for _, pid := range task.ReclaimOrphans() {
+		log.Printf("Killed orphaned process %d", pid)
+	}
+
+	// Open the global log sinks.
+	for _, opts := range settings.Logging.Get().Sinks {
+		sink, err := clog.NewSink(opts)
+		if err != nil {
+			log.Printf("Failed to open log sink %s: %v", opts.Type, err)
+			continue
 		}
+		clog.RegisterHook(sink)
+		s.sinks = append(s.sinks, sink)
 	}
 
+	// Watch the jobs for exceptions.
+	s.tracker = newErrorTracker(s)
+	clog.RegisterHook(s.tracker)
+	go s.tracker.run()
+
 	// Revive jobs.
 	s.reviveJobs()
 
@@ -162,7 +171,11 @@ func Open() (s *Session) {
 	s.RpcServer.Register("daemon", &DaemonService{s})
 	s.RpcServer.Register("event", &EventService{s})
 	s.RpcServer.Register("whiteboard", &WhiteboardService{s})
+	s.RpcServer.Register("logs", &LogService{s})
+	s.RpcServer.Register("errors", &ErrorService{s})
+	s.RpcServer.Register("project", &ProjectService{session: s})
 	s.RpcServer.Router.HandleFunc("/logs", s.LogsHandler)
+	s.RpcServer.Router.HandleFunc("/metrics", s.MetricsHandler)
 	if e := s.RpcServer.ListenAll(); e != nil {
 		s.Close(e)
 		return
@@ -170,17 +183,14 @@ func Open() (s *Session) {
 	return
 }
 
-func (s *Session) StopAll(timeout time.Duration) {
-	waitCtx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
+// Stops all jobs in parallel, each job is given the time its stop sequence needs.
+func (s *Session) StopAll() {
 	wg := sync.WaitGroup{}
 	s.Jobs.Range(func(key, value any) bool {
 		j := value.(*job.Job)
-		j.Stop()
 		wg.Add(1)
 		go func() {
-			j.Join(waitCtx)
+			j.Stop()
 			wg.Done()
 		}()
 		return true
@@ -195,7 +205,7 @@ func (s *Session) Wait() error {
 	case <-killSignal:
 	case <-s.ctx.Done():
 	}
-	s.StopAll(2 * time.Second)
+	s.StopAll()
 	s.Close(nil)
 	return util.Cause(s.ctx)
 }
@@ -210,6 +220,13 @@ func (s *Session) Close(err error) {
 		if s.Database != nil {
 			s.Database.Close()
 		}
+		if s.tracker != nil {
+			clog.RemoveHook(s.tracker)
+		}
+		for _, sink := range s.sinks {
+			clog.RemoveHook(sink)
+			sink.Close()
+		}
 		s.cancel(err)
 	}
 }
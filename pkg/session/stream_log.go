@@ -5,29 +5,82 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/can1357/gosu/pkg/clog"
 	"github.com/can1357/gosu/pkg/job"
 	"github.com/can1357/gosu/pkg/surpc"
+	"github.com/can1357/gosu/pkg/util"
 )
 
 type RpcLogMessage struct {
-	Kind string `json:"kind"`
-	Line string `json:"line"`
+	Kind        string `json:"kind"`
+	Line        string `json:"line"`
+	*clog.Entry        // The structured form of the line, if known.
 }
-type logStreamHook struct {
+
+// Filters applied to the streamed lines.
+type logFilter struct {
+	stream  clog.Stream // StreamMax for all streams.
+	grep    *regexp.Regexp
+	since   time.Time
 	pattern *regexp.Regexp
+}
+
+func (f *logFilter) match(line string, entry *clog.Entry) bool {
+	if f.stream != clog.StreamMax && entry.Stream != f.stream {
+		return false
+	}
+	if f.grep != nil && !f.grep.MatchString(line) && !f.grep.MatchString(entry.Message) {
+		return false
+	}
+	// Lines without a known time are kept.
+	if !f.since.IsZero() && !entry.Time.IsZero() && entry.Time.Before(f.since) {
+		return false
+	}
+	return true
+}
+func parseLogFilter(q url.Values) (f logFilter, err error) {
+	pattern := q.Get("q")
+	if pattern == "" {
+		pattern = ".*"
+	}
+	if f.pattern, err = regexp.Compile("(?i)" + pattern); err != nil {
+		return f, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if g := q.Get("g"); g != "" {
+		if f.grep, err = regexp.Compile(g); err != nil {
+			return f, fmt.Errorf("invalid grep pattern: %w", err)
+		}
+	}
+	switch q.Get("s") {
+	case "":
+		f.stream = clog.StreamMax
+	default:
+		if err = f.stream.UnmarshalText([]byte(q.Get("s"))); err != nil {
+			return
+		}
+	}
+	if since := q.Get("since"); since != "" {
+		f.since, err = util.ParseTime(since)
+	}
+	return
+}
+
+type logStreamHook struct {
+	filter  *logFilter
 	channel chan RpcLogMessage
 }
 
-func (hk *logStreamHook) Write(ns string, line string, kind clog.Stream) {
-	if !hk.pattern.MatchString(ns) {
+func (hk *logStreamHook) Write(line string, entry *clog.Entry) {
+	if !hk.filter.pattern.MatchString(entry.Namespace) || !hk.filter.match(line, entry) {
 		return
 	}
 	select {
-	case hk.channel <- RpcLogMessage{Line: line, Kind: kind.String()}:
+	case hk.channel <- RpcLogMessage{Line: line, Kind: entry.Stream.String(), Entry: entry}:
 	default:
 	}
 }
@@ -40,6 +93,7 @@ func (s *Session) LogsHandler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != "GET" {
 		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
 	}
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -47,21 +101,17 @@ func (s *Session) LogsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the pattern.
-	pattern := r.URL.Query().Get("q")
-	if pattern == "" {
-		pattern = ".*"
-	}
-	pattern = "(?i)" + pattern
-	compiled, err := regexp.Compile(pattern)
+	// Parse the filters.
+	query := r.URL.Query()
+	filter, err := parseLogFilter(query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid pattern: %s", err), 400)
+		http.Error(w, err.Error(), 400)
 		return
 	}
 
 	// Get the tail count
 	tail := 50
-	if t := r.URL.Query().Get("t"); t != "" {
+	if t := query.Get("t"); t != "" {
 		n, e := strconv.Atoi(t)
 		if e == nil {
 			tail = n
@@ -83,24 +133,36 @@ func (s *Session) LogsHandler(w http.ResponseWriter, r *http.Request) {
 	write := func(line RpcLogMessage) { enc.Encode(line); flusher.Flush() }
 
 	// Find all jobs that match the pattern.
-	s.ForEachJobRgx(compiled, func(j *job.Job) error {
-		if logger := j.Logger; logger != nil {
-			for i := clog.StreamStdout; i <= clog.StreamStderr; i++ {
-				lines, err := logger.Tail(tail, i)
-				if err == nil {
-					for _, line := range lines {
-						write(RpcLogMessage{Kind: i.String(), Line: line})
+	if tail > 0 {
+		s.ForEachJobRgx(filter.pattern, func(j *job.Job) error {
+			if logger := j.Logger; logger != nil {
+				for i := clog.StreamStdout; i <= clog.StreamStderr; i++ {
+					if filter.stream != clog.StreamMax && filter.stream != i {
+						continue
+					}
+					lines, err := logger.TailFunc(tail, i, func(line string) bool {
+						entry := logger.ParseLine(line, i)
+						return line != "" && filter.match(line, &entry)
+					})
+					if err == nil {
+						for _, line := range lines {
+							entry := logger.ParseLine(line, i)
+							write(RpcLogMessage{Kind: i.String(), Line: line, Entry: &entry})
+						}
 					}
 				}
 			}
-		}
-		return nil
-	})
+			return nil
+		})
+	}
+	if follow := query.Get("f"); follow == "0" || follow == "false" {
+		return
+	}
 
 	// Insert the hook.
 	ch := make(chan RpcLogMessage, 128)
 	hk := &logStreamHook{
-		pattern: compiled,
+		filter:  &filter,
 		channel: ch,
 	}
 	clog.RegisterHook(hk)
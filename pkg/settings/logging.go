@@ -0,0 +1,11 @@
+package settings
+
+import "github.com/can1357/gosu/pkg/clog"
+
+type logging struct {
+	Sinks []clog.SinkOptions `json:"sinks"` // Sinks receiving the lines of every job.
+}
+
+var Logging = Settings(logging{
+	Sinks: []clog.SinkOptions{},
+})
@@ -30,6 +30,7 @@ type Subdir string
 const (
 	LogDir  Subdir = "log"
 	DataDir Subdir = "db"
+	RunDir  Subdir = "run"
 )
 
 var pathCache = sync.Map{}
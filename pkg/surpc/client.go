@@ -110,6 +110,37 @@ func (c *Client) Close() {
 	}
 }
 
+// Opens a streaming endpoint of the server, the body is decrypted if the connection is secure.
+func (c *Client) Stream(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	u := *c.URL
+	u.Path = path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	req.Header, iv = c.next()
+	req.Header = req.Header.Clone()
+
+	response, err := c.Http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		defer response.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		if msg := bytes.TrimSpace(msg); len(msg) != 0 {
+			return nil, errors.New(string(msg))
+		}
+		return nil, fmt.Errorf("HTTP Error %d: %s", response.StatusCode, response.Status)
+	}
+	if iv != nil {
+		return &RWC{Reader: NewCipherReader(response.Body, iv), Closer: response.Body}, nil
+	}
+	return response.Body, nil
+}
+
 func (c *Client) Call(serviceMethod string, reply any, args any) error {
 	ws, err := c.Open(false)
 	if err != nil && ws != nil {
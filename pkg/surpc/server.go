@@ -2,6 +2,7 @@ package surpc
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -23,10 +24,19 @@ type Server struct {
 	listeners  []net.Listener
 }
 
+// Returns true if the request comes from the loopback interface.
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (s *Server) Authorize(w http.ResponseWriter, r *http.Request) (secure []byte, ok bool) {
 	if secret := r.Header.Get("X-Secret"); secret == "" {
-		ip := net.ParseIP(strings.Split(r.RemoteAddr, ":")[0])
-		if ip == nil || !ip.IsLoopback() {
+		if !fromLoopback(r) {
 			http.Error(w, "Forbidden", 444)
 			return
 		}
@@ -49,6 +59,20 @@ func (s *Server) Authorize(w http.ResponseWriter, r *http.Request) (secure []byt
 	return
 }
 
+// Authorizes plain requests such as metric scrapes, either from the loopback interface
+// or with the secret as a bearer token.
+func (s *Server) AuthorizeToken(w http.ResponseWriter, r *http.Request) bool {
+	if fromLoopback(r) {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secret := settings.Rpc.Get().Secret; !found || secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		http.Error(w, "Forbidden", 444)
+		return false
+	}
+	return true
+}
+
 func (s *Server) RpcHandler(w http.ResponseWriter, r *http.Request) {
 	secure, ok := s.Authorize(w, r)
 	if !ok {
@@ -0,0 +1,283 @@
+//go:build linux
+// +build linux
+
+package task
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	cgroupMount     = "/sys/fs/cgroup"
+	cgroupCpuPeriod = 100000
+)
+
+var cgroupControllers = []string{"memory", "cpu", "pids", "io"}
+
+// Enables the controllers we use for the children of the given cgroup, ignoring the ones that are not available.
+func enableControllers(dir string) error {
+	var errs []error
+	for _, ctl := range cgroupControllers {
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+ctl), 0); err != nil {
+			errs = append(errs, fmt.Errorf("enabling %s controller: %w", ctl, err))
+		}
+	}
+	if len(errs) == len(cgroupControllers) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// Returns the cgroup of the current process relative to the mount point.
+func selfCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("no cgroup v2 hierarchy found")
+}
+
+// Moves the daemon into a leaf of its cgroup, a cgroup with processes of its own can not delegate
+// controllers to its children.
+func moveToLeaf(dir string) error {
+	leaf := filepath.Join(dir, "daemon")
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0)
+}
+
+// The gosu owned subtree all instances are placed under, either at the root of the hierarchy or
+// under the cgroup of the daemon if it was delegated to us.
+var cgroupRoot = sync.OnceValues(func() (string, error) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(cgroupMount, &fs); err != nil {
+		return "", err
+	} else if fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return "", errors.New("cgroup v2 is not mounted")
+	}
+
+	self, err := selfCgroup()
+	if err != nil {
+		return "", err
+	}
+	var errs []error
+	for _, parent := range []string{cgroupMount, filepath.Join(cgroupMount, self)} {
+		if parent != cgroupMount {
+			if err := moveToLeaf(parent); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		root := filepath.Join(parent, "gosu")
+		if err := os.Mkdir(root, 0755); err != nil && !os.IsExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		if err := enableControllers(parent); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := enableControllers(root); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return root, nil
+	}
+	return "", errors.Join(errs...)
+})
+
+// Whether the kernel can start a process directly inside a cgroup, added in 5.7.
+var cloneIntoCgroup = sync.OnceValue(func() bool {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return false
+	}
+	var major, minor int
+	fmt.Sscanf(unix.ByteSliceToString(uts.Release[:]), "%d.%d", &major, &minor)
+	return major > 5 || (major == 5 && minor >= 7)
+})
+
+type cgroup struct {
+	path     string
+	fd       *os.File
+	attached bool
+	oomKills int
+}
+
+func cgroupLimit(value int) string {
+	if value <= 0 {
+		return "max"
+	}
+	return strconv.Itoa(value)
+}
+
+var cgroupSeq atomic.Uint64
+
+// Creates the cgroup for an instance and applies the resource limits in the options.
+func newCgroup(name string, o Options) (*cgroup, error) {
+	root, err := cgroupRoot()
+	if err != nil {
+		return nil, err
+	}
+	// Every launch gets a cgroup of its own so that a replacement never shares it with the
+	// instance it replaces.
+	name = strings.NewReplacer("/", ".", " ", "_").Replace(name)
+	c := &cgroup{}
+	for {
+		c.path = filepath.Join(root, fmt.Sprintf("%s.%d", name, cgroupSeq.Add(1)))
+		if err := os.Mkdir(c.path, 0755); err == nil {
+			break
+		} else if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+
+	limits := map[string]string{
+		"memory.max": cgroupLimit(o.MaxMemory.Value),
+		"pids.max":   cgroupLimit(o.MaxPids),
+		"cpu.max":    "max",
+	}
+	if o.MaxCpu > 0 {
+		limits["cpu.max"] = fmt.Sprintf("%d %d", int(o.MaxCpu*cgroupCpuPeriod), cgroupCpuPeriod)
+	}
+	if o.IoWeight > 0 {
+		limits["io.weight"] = fmt.Sprintf("default %d", o.IoWeight)
+	}
+	for file, value := range limits {
+		if err := os.WriteFile(filepath.Join(c.path, file), []byte(value), 0); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("setting %s: %w", file, err)
+		}
+	}
+
+	c.oomKills = c.readOomKills()
+	if c.fd, err = os.Open(c.path); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Makes the command start directly inside the cgroup if the kernel supports it, otherwise the
+// process is moved into it once it is started.
+func (c *cgroup) Attach(cmd *exec.Cmd) {
+	if !cloneIntoCgroup() {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.fd.Fd())
+	c.attached = true
+}
+
+// Moves the started process into the cgroup unless it was started inside it.
+func (c *cgroup) Started(pid int) error {
+	if c.attached {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(c.path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0)
+}
+
+func (c *cgroup) readOomKills() int {
+	data, err := os.ReadFile(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			n, _ := strconv.Atoi(value)
+			return n
+		}
+	}
+	return 0
+}
+
+// Returns the number of processes killed by the OOM killer since the cgroup was created.
+func (c *cgroup) OomKills() int {
+	return c.readOomKills() - c.oomKills
+}
+
+// Removes the cgroup, waiting briefly for the kernel to release the exited processes.
+func (c *cgroup) Close() error {
+	if c.fd != nil {
+		c.fd.Close()
+		c.fd = nil
+	}
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(c.path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return err
+}
+
+// Returns the processes currently in the cgroup.
+func readCgroupProcs(path string) (pids []int32) {
+	data, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(line); err == nil {
+			pids = append(pids, int32(pid))
+		}
+	}
+	return
+}
+func (c *cgroup) Path() string {
+	return c.path
+}
+func (c *cgroup) Procs() []int32 {
+	return readCgroupProcs(c.path)
+}
+
+// Kills every process in the cgroup, including the ones that left the process group.
+func killCgroup(path string) {
+	if os.WriteFile(filepath.Join(path, "cgroup.kill"), []byte("1"), 0) == nil {
+		return
+	}
+	for _, pid := range readCgroupProcs(path) {
+		syscall.Kill(int(pid), syscall.SIGKILL)
+	}
+}
+func (c *cgroup) Kill() {
+	killCgroup(c.path)
+}
+
+// Kills the processes left in the cgroup of an instance of a previous daemon and removes it.
+func reclaimCgroup(path string) (pids []int32) {
+	if path == "" || !strings.HasPrefix(path, cgroupMount+"/") {
+		return nil
+	}
+	pids = readCgroupProcs(path)
+	if len(pids) != 0 {
+		killCgroup(path)
+	}
+	(&cgroup{path: path}).Close()
+	return
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package task
+
+import (
+	"errors"
+	"os/exec"
+)
+
+type cgroup struct{}
+
+func newCgroup(name string, o Options) (*cgroup, error) {
+	return nil, errors.New("cgroups are only supported on linux")
+}
+func (c *cgroup) Attach(cmd *exec.Cmd)  {}
+func (c *cgroup) Started(pid int) error { return nil }
+func (c *cgroup) OomKills() int         { return 0 }
+func (c *cgroup) Close() error          { return nil }
+func (c *cgroup) Path() string          { return "" }
+func (c *cgroup) Procs() []int32        { return nil }
+func (c *cgroup) Kill()                 {}
+
+func reclaimCgroup(path string) []int32 {
+	return nil
+}
@@ -0,0 +1,242 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// An environment being built, later variables override earlier ones.
+type environ struct {
+	keys []string
+	vars map[string]string
+}
+
+func (e *environ) set(key, value string) {
+	if _, ok := e.vars[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.vars[key] = value
+}
+
+// Looks up a variable set so far, falling back to the environment of the daemon even if it is
+// not inherited.
+func (e *environ) lookup(name string) (string, bool) {
+	if value, ok := e.vars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// Expands ${NAME}, $NAME, ${NAME:-default} and ${NAME-default}, $$ is a literal $.
+func expandEnv(s string, lookup func(string) (string, bool)) string {
+	return os.Expand(s, func(ref string) string {
+		name, def, unsetOnly := ref, "", false
+		if i := strings.Index(ref, ":-"); i >= 0 {
+			name, def = ref[:i], ref[i+2:]
+		} else if i := strings.IndexByte(ref, '-'); i >= 0 {
+			name, def, unsetOnly = ref[:i], ref[i+1:], true
+		} else if ref == "$" {
+			return "$"
+		}
+		value, ok := lookup(name)
+		if !ok || (value == "" && !unsetOnly) {
+			return expandEnv(def, lookup)
+		}
+		return value
+	})
+}
+func (e *environ) expand(s string) string {
+	return expandEnv(s, e.lookup)
+}
+
+// Sets the variables of the map in a stable order, expanding them if asked to. A reference to
+// another variable of the map resolves to its expanded value regardless of the order, a
+// reference to itself or a cycle resolves to the value set before.
+func (e *environ) merge(vars map[string]string, interpolate bool) {
+	keys := lo.Keys(vars)
+	sort.Strings(keys)
+	if !interpolate {
+		for _, k := range keys {
+			e.set(k, vars[k])
+		}
+		return
+	}
+
+	resolved := map[string]string{}
+	resolving := map[string]bool{}
+	var resolve func(k string) string
+	lookup := func(name string) (string, bool) {
+		if _, ok := vars[name]; ok && !resolving[name] {
+			return resolve(name), true
+		}
+		return e.lookup(name)
+	}
+	resolve = func(k string) string {
+		if value, ok := resolved[k]; ok {
+			return value
+		}
+		resolving[k] = true
+		value := expandEnv(vars[k], lookup)
+		delete(resolving, k)
+		resolved[k] = value
+		return value
+	}
+	for _, k := range keys {
+		resolve(k)
+	}
+	for _, k := range keys {
+		e.set(k, resolved[k])
+	}
+}
+
+func (e *environ) list() []string {
+	return lo.Map(e.keys, func(k string, _ int) string { return k + "=" + e.vars[k] })
+}
+
+// Reads a dotenv file: KEY=value lines, optionally prefixed with export, and # comments.
+// Double quoted values support escapes, other values are expanded if asked to unless they
+// are single quoted.
+func (e *environ) load(path string, interpolate bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return fmt.Errorf("%s:%d: expected KEY=value", path, n)
+		}
+		value = strings.TrimSpace(value)
+		expand := func(s string) string {
+			if interpolate {
+				return e.expand(s)
+			}
+			return s
+		}
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			e.set(key, value[1:len(value)-1])
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+			e.set(key, expand(replacer.Replace(value[1:len(value)-1])))
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			e.set(key, expand(value))
+		}
+	}
+	return sc.Err()
+}
+
+// Builds the environment of the process: the daemon's unless not inherited, the env files,
+// then env with the overlay of the selected profile.
+func (t *TaskRun) Environ(o Options) ([]string, error) {
+	e := &environ{vars: map[string]string{}}
+	if o.InheritEnv == nil || *o.InheritEnv {
+		for _, kv := range os.Environ() {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				e.set(k, v)
+			}
+		}
+	}
+	for _, path := range t.EnvFile {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(t.Cwd, path)
+		}
+		if err := e.load(path, t.ExpandEnv); err != nil {
+			return nil, err
+		}
+	}
+	vars := t.Env
+	if o.Profile != "" {
+		profile, ok := t.Profiles[o.Profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown environment profile: %s", o.Profile)
+		}
+		vars = lo.Assign(t.Env, profile)
+	}
+	e.merge(vars, t.ExpandEnv)
+	return e.list(), nil
+}
+
+// One or more dotenv files, a single file may be given as a string.
+type EnvFiles []string
+
+func (f *EnvFiles) UnmarshalJSON(data []byte) error {
+	if len(data) != 0 && data[0] == '"' {
+		var path string
+		if err := json.Unmarshal(data, &path); err != nil {
+			return err
+		}
+		*f = EnvFiles{path}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(f))
+}
+
+// The env_<profile> keys are gathered into the profiles.
+type taskRunJSON TaskRun
+
+func (t *TaskRun) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*taskRunJSON)(t)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key, raw := range fields {
+		profile, found := strings.CutPrefix(key, "env_")
+		if !found || profile == "file" {
+			continue
+		}
+		var vars map[string]string
+		if err := json.Unmarshal(raw, &vars); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if t.Profiles == nil {
+			t.Profiles = map[string]map[string]string{}
+		}
+		t.Profiles[profile] = vars
+	}
+	return nil
+}
+func (t TaskRun) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(taskRunJSON(t))
+	if err != nil || len(t.Profiles) == 0 {
+		return data, err
+	}
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	profiles := lo.Keys(t.Profiles)
+	sort.Strings(profiles)
+	for _, profile := range profiles {
+		vars, err := json.Marshal(t.Profiles[profile])
+		if err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal("env_" + profile)
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(vars)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
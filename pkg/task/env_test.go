@@ -0,0 +1,238 @@
+package task
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	vars := map[string]string{"A": "a", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"$A", "a"},
+		{"${A}/x", "a/x"},
+		{"${UNSET}", ""},
+		{"${UNSET:-def}", "def"},
+		{"${EMPTY:-def}", "def"},
+		{"${UNSET-def}", "def"},
+		{"${EMPTY-def}", ""},
+		{"${A:-def}", "a"},
+		{"${UNSET:-$A}", "a"},
+		{"$$A", "$A"},
+		{"cost: $$5", "cost: $5"},
+	}
+	for _, tt := range tests {
+		if got := expandEnv(tt.in, lookup); got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEnvironLoad(t *testing.T) {
+	tests := []struct {
+		line     string
+		key      string
+		want     string // With interpolation.
+		wantRaw  string // Without interpolation.
+		wantFail bool
+	}{
+		{line: "A=1", key: "A", want: "1", wantRaw: "1"},
+		{line: "export A=1", key: "A", want: "1", wantRaw: "1"},
+		{line: " A = spaced value ", key: "A", want: "spaced value", wantRaw: "spaced value"},
+		{line: "A=value # comment", key: "A", want: "value", wantRaw: "value"},
+		{line: "A=a#b", key: "A", want: "a#b", wantRaw: "a#b"},
+		{line: "A=", key: "A", want: "", wantRaw: ""},
+		{line: "A=${BASE}/x", key: "A", want: "base/x", wantRaw: "${BASE}/x"},
+		{line: "A='${BASE} # $$'", key: "A", want: "${BASE} # $$", wantRaw: "${BASE} # $$"},
+		{line: `A="${BASE} # x"`, key: "A", want: "base # x", wantRaw: "${BASE} # x"},
+		{line: `A="a\nb\t\"c\" \\"`, key: "A", want: "a\nb\t\"c\" \\", wantRaw: "a\nb\t\"c\" \\"},
+		{line: "A", wantFail: true},
+		{line: "=value", wantFail: true},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, ".env")
+		data := "# leading comment\n\n" + tt.line + "\n"
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, interpolate := range []bool{true, false} {
+			e := &environ{vars: map[string]string{"BASE": "base"}}
+			err := e.load(path, interpolate)
+			if tt.wantFail {
+				if err == nil {
+					t.Errorf("load(%q) succeeded, want an error", tt.line)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("load(%q): %v", tt.line, err)
+				continue
+			}
+			want := tt.wantRaw
+			if interpolate {
+				want = tt.want
+			}
+			if got, ok := e.vars[tt.key]; !ok || got != want {
+				t.Errorf("load(%q), interpolate = %v: %s = %q, want %q", tt.line, interpolate, tt.key, got, want)
+			}
+		}
+	}
+}
+
+func TestEnvironMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		prior       map[string]string
+		vars        map[string]string
+		interpolate bool
+		want        map[string]string
+	}{
+		{
+			name: "literal",
+			vars: map[string]string{"A": "${B}", "B": "b"},
+			want: map[string]string{"A": "${B}", "B": "b"},
+		},
+		{
+			name:        "any order",
+			vars:        map[string]string{"A": "${B}-a", "B": "${C}-b", "C": "c"},
+			interpolate: true,
+			want:        map[string]string{"A": "c-b-a", "B": "c-b", "C": "c"},
+		},
+		{
+			name:        "self reference",
+			prior:       map[string]string{"GOSU_TEST_PATH": "/bin"},
+			vars:        map[string]string{"GOSU_TEST_PATH": "/opt:${GOSU_TEST_PATH}"},
+			interpolate: true,
+			want:        map[string]string{"GOSU_TEST_PATH": "/opt:/bin"},
+		},
+		{
+			name:        "cycle",
+			prior:       map[string]string{"GOSU_TEST_A": "prior"},
+			vars:        map[string]string{"GOSU_TEST_A": "${GOSU_TEST_B}", "GOSU_TEST_B": "${GOSU_TEST_A}"},
+			interpolate: true,
+			want:        map[string]string{"GOSU_TEST_A": "prior", "GOSU_TEST_B": "prior"},
+		},
+		{
+			name:        "cycle without prior",
+			vars:        map[string]string{"GOSU_TEST_A": "a${GOSU_TEST_B}", "GOSU_TEST_B": "b${GOSU_TEST_A}"},
+			interpolate: true,
+			want:        map[string]string{"GOSU_TEST_A": "ab", "GOSU_TEST_B": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &environ{vars: map[string]string{}}
+			for k, v := range tt.prior {
+				e.set(k, v)
+			}
+			e.merge(tt.vars, tt.interpolate)
+			if !reflect.DeepEqual(e.vars, tt.want) {
+				t.Errorf("merge = %v, want %v", e.vars, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskRunEnviron(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("A=file\nB=file\nC=file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := &TaskRun{
+		Cwd:       dir,
+		EnvFile:   EnvFiles{".env"},
+		Env:       map[string]string{"B": "env", "C": "env", "D": "${A}"},
+		Profiles:  map[string]map[string]string{"production": {"C": "production"}},
+		ExpandEnv: true,
+	}
+	inherit := false
+	tests := []struct {
+		profile string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{"A=file", "B=env", "C=env", "D=file"}, false},
+		{"production", []string{"A=file", "B=env", "C=production", "D=file"}, false},
+		{"staging", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := run.Environ(Options{InheritEnv: &inherit, Profile: tt.profile})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Environ(%q): err = %v, want error = %v", tt.profile, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Environ(%q) = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestTaskRunProfilesJSON(t *testing.T) {
+	tests := []struct {
+		data     string
+		profiles map[string]map[string]string
+		files    EnvFiles
+		wantErr  bool
+	}{
+		{
+			data: `{"cwd":"/","env":{"A":"1"}}`,
+		},
+		{
+			data:     `{"cwd":"/","env":{"A":"1"},"env_production":{"A":"2"},"env_staging":{"B":"3"}}`,
+			profiles: map[string]map[string]string{"production": {"A": "2"}, "staging": {"B": "3"}},
+		},
+		{
+			data:  `{"cwd":"/","env_file":".env"}`,
+			files: EnvFiles{".env"},
+		},
+		{
+			data:  `{"cwd":"/","env_file":[".env",".env.local"]}`,
+			files: EnvFiles{".env", ".env.local"},
+		},
+		{
+			data:    `{"cwd":"/","env_production":"NODE_ENV=production"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var run TaskRun
+		if err := json.Unmarshal([]byte(tt.data), &run); err != nil {
+			if !tt.wantErr {
+				t.Errorf("Unmarshal(%s): %v", tt.data, err)
+			}
+			continue
+		} else if tt.wantErr {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", tt.data)
+			continue
+		}
+		if !reflect.DeepEqual(run.Profiles, tt.profiles) {
+			t.Errorf("Unmarshal(%s): profiles = %v, want %v", tt.data, run.Profiles, tt.profiles)
+		}
+		if !reflect.DeepEqual(run.EnvFile, tt.files) {
+			t.Errorf("Unmarshal(%s): env files = %v, want %v", tt.data, run.EnvFile, tt.files)
+		}
+
+		data, err := json.Marshal(run)
+		if err != nil {
+			t.Errorf("Marshal(%s): %v", tt.data, err)
+			continue
+		}
+		var again TaskRun
+		if err := json.Unmarshal(data, &again); err != nil {
+			t.Errorf("Unmarshal(%s): %v", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(again, run) {
+			t.Errorf("round trip of %s = %s", tt.data, data)
+		}
+	}
+}
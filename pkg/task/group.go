@@ -0,0 +1,96 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/can1357/gosu/pkg/settings"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Tracks every process started on behalf of an instance, so that they can be inspected and
+// signalled together rather than just the direct child.
+type procGroup struct {
+	leader  *os.Process
+	cg      *cgroup   // The cgroup of the instance, if any.
+	created time.Time // The creation time of the leader.
+	record  string    // The file recording the group for reclamation after a crash.
+}
+
+func newProcGroup(cmd *exec.Cmd, cg *cgroup, ns string) *procGroup {
+	g := &procGroup{leader: cmd.Process, cg: cg}
+	if proc, err := process.NewProcess(int32(cmd.Process.Pid)); err == nil {
+		if epo, err := proc.CreateTime(); err == nil {
+			g.created = time.UnixMilli(epo)
+		}
+	}
+	// The record holds the creation time and namespace of the leader, then the cgroup if any.
+	record := fmt.Sprintf("%d %s\n", g.created.UnixMilli(), ns)
+	if cg != nil {
+		record += cg.Path() + "\n"
+	}
+	g.record = filepath.Join(settings.RunDir.Path(), strconv.Itoa(cmd.Process.Pid))
+	os.WriteFile(g.record, []byte(record), 0644)
+	return g
+}
+
+// Returns the processes in the group, starting with the leader if it is still alive.
+func (g *procGroup) Pids() []int32 {
+	if g.cg != nil {
+		if pids := g.cg.Procs(); len(pids) != 0 {
+			return pids
+		}
+	}
+	return groupMembers(g.leader.Pid, g.created)
+}
+
+// Kills all processes in the group.
+func (g *procGroup) Kill() error {
+	if g.cg != nil {
+		g.cg.Kill()
+	}
+	return killGroup(g.leader)
+}
+
+// Kills the processes that outlived the leader and forgets the group.
+func (g *procGroup) Close() (leftover []int32) {
+	leftover = g.Pids()
+	if len(leftover) != 0 {
+		g.Kill()
+	}
+	os.Remove(g.record)
+	return
+}
+
+// Kills the process groups left behind by a previous daemon that did not exit cleanly,
+// returns the processes that were killed.
+func ReclaimOrphans() (pids []int32) {
+	dir := settings.RunDir.Path()
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		record := filepath.Join(dir, entry.Name())
+		pid, err := strconv.Atoi(entry.Name())
+		data, _ := os.ReadFile(record)
+		os.Remove(record)
+		if err != nil {
+			continue
+		}
+		header, cgroup, _ := strings.Cut(string(data), "\n")
+		pids = append(pids, reclaimCgroup(strings.TrimSpace(cgroup))...)
+		epo, _, _ := strings.Cut(header, " ")
+		created, _ := strconv.ParseInt(epo, 10, 64)
+		members := groupMembers(pid, time.UnixMilli(created))
+		if len(members) != 0 {
+			if proc, err := os.FindProcess(pid); err == nil {
+				killGroup(proc)
+			}
+			pids = append(pids, members...)
+		}
+	}
+	return
+}
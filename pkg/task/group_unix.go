@@ -0,0 +1,65 @@
+//go:build !windows
+// +build !windows
+
+package task
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Starts the command as the leader of a new process group, so that the whole tree can be signalled.
+func prepareGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return killGroup(cmd.Process)
+	}
+}
+
+// Returns the members of the process group led by pid that were created after the leader.
+func groupMembers(pgid int, created time.Time) (pids []int32) {
+	all, err := process.Pids()
+	if err != nil {
+		return nil
+	}
+	for _, pid := range all {
+		if id, err := syscall.Getpgid(int(pid)); err != nil || id != pgid {
+			continue
+		}
+		if !created.IsZero() {
+			proc, err := process.NewProcess(pid)
+			if err != nil {
+				continue
+			}
+			if epo, err := proc.CreateTime(); err != nil || time.UnixMilli(epo).Before(created) {
+				continue
+			}
+		}
+		pids = append(pids, pid)
+	}
+	return
+}
+
+func signalGroup(leader *os.Process, sig os.Signal) error {
+	if s, ok := sig.(syscall.Signal); ok {
+		if err := syscall.Kill(-leader.Pid, s); err == nil {
+			return nil
+		}
+	}
+	return leader.Signal(sig)
+}
+func killGroup(leader *os.Process) error {
+	return signalGroup(leader, syscall.SIGKILL)
+}
+
+// Sends the signal to every process in the group.
+func (g *procGroup) Signal(sig os.Signal) error {
+	return signalGroup(g.leader, sig)
+}
@@ -0,0 +1,66 @@
+//go:build windows
+// +build windows
+
+package task
+
+import (
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Windows has no process groups we can signal, the tree is walked instead.
+func prepareGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return killGroup(cmd.Process)
+	}
+}
+
+func walkTree(p *process.Process, pids *[]int32) {
+	if lo.Contains(*pids, p.Pid) {
+		return
+	}
+	*pids = append(*pids, p.Pid)
+	if children, err := p.Children(); err == nil {
+		for _, child := range children {
+			walkTree(child, pids)
+		}
+	}
+}
+
+// Returns the process tree rooted at pid, if the root is the process that was created at the given time.
+func groupMembers(pid int, created time.Time) (pids []int32) {
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil
+	}
+	if !created.IsZero() {
+		if epo, err := proc.CreateTime(); err != nil || !time.UnixMilli(epo).Equal(created) {
+			return nil
+		}
+	}
+	walkTree(proc, &pids)
+	return
+}
+
+func killGroup(leader *os.Process) error {
+	for _, pid := range groupMembers(leader.Pid, time.Time{}) {
+		if pid != int32(leader.Pid) {
+			if proc, err := os.FindProcess(int(pid)); err == nil {
+				proc.Kill()
+			}
+		}
+	}
+	return leader.Kill()
+}
+
+// Sends the signal to the process, interrupts can not be delivered so the tree is killed instead.
+func (g *procGroup) Signal(sig os.Signal) error {
+	if sig == os.Kill || sig == os.Interrupt {
+		return killGroup(g.leader)
+	}
+	return g.leader.Signal(sig)
+}
@@ -0,0 +1,119 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/can1357/gosu/pkg/ipc"
+	"github.com/can1357/gosu/pkg/util"
+)
+
+type HealthCheck struct {
+	Http      string                `json:"http,omitempty"`      // The path or URL to request, paths are requested over the instance's IPC socket.
+	Status    int                   `json:"status,omitempty"`    // The expected status code, 0 accepts any 2xx or 3xx.
+	Tcp       string                `json:"tcp,omitempty"`       // The address to connect to.
+	Exec      string                `json:"exec,omitempty"`      // The shell command to run, healthy if it exits with status 0.
+	Interval  util.ParsableDuration `json:"interval,omitempty"`  // The time between probes.
+	Timeout   util.ParsableDuration `json:"timeout,omitempty"`   // The time to wait for a single probe.
+	Threshold int                   `json:"threshold,omitempty"` // The number of consecutive failures before the instance is restarted.
+}
+
+func (hc *HealthCheck) WithDefaults() {
+	if hc.Http == "" && hc.Tcp == "" && hc.Exec == "" {
+		hc.Http = "/"
+	}
+	if !hc.Interval.IsPositive() {
+		hc.Interval = util.Duration(10 * time.Second)
+	}
+	if !hc.Timeout.IsPositive() {
+		hc.Timeout = util.Duration(2 * time.Second)
+	}
+	if hc.Threshold <= 0 {
+		hc.Threshold = 3
+	}
+}
+
+// Whether the probe requests a path over the instance's IPC socket, which only exists behind a proxy.
+func (hc *HealthCheck) viaProxy() bool {
+	return hc.Exec == "" && hc.Tcp == "" && !strings.HasPrefix(hc.Http, "http://") && !strings.HasPrefix(hc.Http, "https://")
+}
+
+func (hc *HealthCheck) probeHttp(ctx context.Context, h *processRunner) error {
+	client := http.DefaultClient
+	url := hc.Http
+	if hc.viaProxy() {
+		if h.ipc == "" {
+			return fmt.Errorf("health check path %s requires a proxy", url)
+		}
+		client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return ipc.DialContext(ctx, h.ipc)
+				},
+			},
+		}
+		url = "http://localhost" + url
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if hc.Status != 0 {
+		if res.StatusCode != hc.Status {
+			return fmt.Errorf("unexpected status code %d, expected %d", res.StatusCode, hc.Status)
+		}
+	} else if res.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return nil
+}
+func (hc *HealthCheck) probeTcp(ctx context.Context) error {
+	var dialer net.Dialer
+	con, err := dialer.DialContext(ctx, "tcp", hc.Tcp)
+	if err != nil {
+		return err
+	}
+	return con.Close()
+}
+func (hc *HealthCheck) probeExec(ctx context.Context, h *processRunner, env []string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hc.Exec)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hc.Exec)
+	}
+	cmd.Dir = h.Cwd
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if out := strings.TrimSpace(string(out)); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// Runs a single probe against the instance, returns nil if it is healthy.
+func (hc *HealthCheck) Probe(ctx context.Context, h *processRunner, env []string) error {
+	ctx, cancel := hc.Timeout.Timeout(ctx)
+	defer cancel()
+	switch {
+	case hc.Exec != "":
+		return hc.probeExec(ctx, h, env)
+	case hc.Tcp != "":
+		return hc.probeTcp(ctx)
+	default:
+		return hc.probeHttp(ctx, h)
+	}
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/can1357/gosu/pkg/util"
+)
+
+// Describes how a process exited before being restarted.
+type Exit struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Code      int       `json:"code"`             // The exit code, -1 if it was killed by a signal or never started.
+	Signal    string    `json:"signal,omitempty"` // The signal that killed the process, if any.
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Restarted bool      `json:"restarted"` // Whether the process was restarted after the exit.
+}
+
+func NewExit(ns string, err error) (e Exit) {
+	e.Time = time.Now()
+	e.Namespace = ns
+	e.Code = -1
+	e.Status = StatusFromErr(err)
+	if err == nil {
+		e.Code = 0
+		return
+	}
+	e.Error = err.Error()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		e.Code = ee.ExitCode()
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			e.Signal = util.Signal(ws.Signal()).String()
+		}
+	}
+	return
+}
+
+// Called whenever a retried task exits, installed through the context of the worker.
+type ExitHook func(e Exit)
+
+type exitHookKey struct{}
+
+func (h ExitHook) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, exitHookKey{}, h)
+}
+func ExitHookFromContext(ctx context.Context) ExitHook {
+	if ctx != nil {
+		if h, ok := ctx.Value(exitHookKey{}).(ExitHook); ok {
+			return h
+		}
+	}
+	return func(Exit) {}
+}
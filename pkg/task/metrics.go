@@ -0,0 +1,136 @@
+package task
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	metricsRecent  = 5 * time.Minute // Windows up to this long are returned at the inspection rate.
+	metricsPeriod  = time.Minute     // Resolution the older samples are averaged to.
+	metricsHistory = 24 * time.Hour  // Downsampled samples are kept for this long.
+)
+
+// Resource usage of a task at a point in time.
+type MetricSample struct {
+	Time    time.Time `json:"time"`
+	Cpu     float64   `json:"cpu"`
+	Mem     float64   `json:"mem"`
+	Fds     float64   `json:"fds"`
+	Threads float64   `json:"threads"`
+}
+
+func (s *MetricSample) add(o MetricSample) {
+	s.Cpu += o.Cpu
+	s.Mem += o.Mem
+	s.Fds += o.Fds
+	s.Threads += o.Threads
+}
+func (s MetricSample) scale(f float64) MetricSample {
+	s.Cpu *= f
+	s.Mem *= f
+	s.Fds *= f
+	s.Threads *= f
+	return s
+}
+
+// Fixed size buffer overwriting the oldest sample.
+type sampleRing struct {
+	buf  []MetricSample
+	next int
+	full bool
+}
+
+func newSampleRing(n int) sampleRing {
+	return sampleRing{buf: make([]MetricSample, n)}
+}
+func (r *sampleRing) push(s MetricSample) {
+	r.buf[r.next] = s
+	if r.next++; r.next == len(r.buf) {
+		r.next, r.full = 0, true
+	}
+}
+
+// Appends the samples taken after the given time, oldest first.
+func (r *sampleRing) appendSince(out []MetricSample, since time.Time) []MetricSample {
+	if r.full {
+		for _, s := range r.buf[r.next:] {
+			if s.Time.After(since) {
+				out = append(out, s)
+			}
+		}
+	}
+	for _, s := range r.buf[:r.next] {
+		if s.Time.After(since) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Bounded history of the resource usage of a worker, recent samples are kept as they are
+// and older ones are averaged per minute.
+type Metrics struct {
+	mu      sync.Mutex
+	recent  sampleRing
+	periods sampleRing
+	acc     MetricSample // Sum of the samples of the current period.
+	n       int          // Number of samples in the current period.
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		recent:  newSampleRing(int((metricsRecent + metricsPeriod) / inspectRate)),
+		periods: newSampleRing(int(metricsHistory / metricsPeriod)),
+	}
+}
+
+// Records a report, reports of stopped processes are skipped.
+func (m *Metrics) Add(r Report) {
+	if r.IsZero() {
+		return
+	}
+	m.record(MetricSample{
+		Time:    time.Now(),
+		Cpu:     r.Cpu,
+		Mem:     r.Mem,
+		Fds:     float64(r.Fds),
+		Threads: float64(r.Threads),
+	})
+}
+func (m *Metrics) record(s MetricSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recent.push(s)
+	if period := s.Time.Truncate(metricsPeriod); m.n != 0 && !period.Equal(m.acc.Time) {
+		m.periods.push(m.average())
+		m.n = 0
+	}
+	if m.n == 0 {
+		m.acc = MetricSample{Time: s.Time.Truncate(metricsPeriod)}
+	}
+	m.acc.add(s)
+	m.n++
+}
+
+// Average of the current period.
+func (m *Metrics) average() MetricSample {
+	avg := m.acc.scale(1 / float64(m.n))
+	avg.Time = m.acc.Time
+	return avg
+}
+
+// Returns the samples taken after the given time, oldest first. Windows that fit in the recent
+// samples are returned at the inspection rate, longer ones per minute.
+func (m *Metrics) Since(since time.Time) []MetricSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !since.IsZero() && time.Since(since) <= metricsRecent+metricsPeriod {
+		return m.recent.appendSince(nil, since)
+	}
+	out := m.periods.appendSince(nil, since.Truncate(metricsPeriod).Add(-1))
+	if m.n != 0 {
+		out = append(out, m.average())
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSampleRing(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return base.Add(time.Duration(i) * time.Second) }
+	tests := []struct {
+		name   string
+		pushed int
+		since  time.Time
+		want   []int
+	}{
+		{"empty", 0, time.Time{}, nil},
+		{"partial", 2, time.Time{}, []int{1, 2}},
+		{"full", 3, time.Time{}, []int{1, 2, 3}},
+		{"wrapped", 5, time.Time{}, []int{3, 4, 5}},
+		{"wrapped twice", 7, time.Time{}, []int{5, 6, 7}},
+		{"since", 5, at(3), []int{4, 5}},
+		{"since all", 5, at(5), nil},
+	}
+	for _, tt := range tests {
+		r := newSampleRing(3)
+		for i := 1; i <= tt.pushed; i++ {
+			r.push(MetricSample{Time: at(i)})
+		}
+		var got []int
+		for _, s := range r.appendSince(nil, tt.since) {
+			got = append(got, int(s.Time.Sub(base)/time.Second))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: appendSince = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsDownsampling(t *testing.T) {
+	base := time.Now().Truncate(metricsPeriod).Add(-10 * metricsPeriod)
+	m := NewMetrics()
+	m.Add(Report{}) // Stopped processes are not recorded.
+	for _, s := range []struct {
+		offset time.Duration
+		cpu    float64
+	}{
+		{0, 1},
+		{30 * time.Second, 3},
+		{time.Minute, 5},
+		{2*time.Minute + 10*time.Second, 7},
+		{2*time.Minute + 20*time.Second, 9},
+	} {
+		m.record(MetricSample{Time: base.Add(s.offset), Cpu: s.cpu, Mem: 2 * s.cpu})
+	}
+
+	type period struct {
+		offset time.Duration
+		cpu    float64
+	}
+	tests := []struct {
+		name  string
+		since time.Time
+		want  []period
+	}{
+		{"everything", time.Time{}, []period{{0, 2}, {time.Minute, 5}, {2 * time.Minute, 8}}},
+		{"from a period", base.Add(time.Minute), []period{{time.Minute, 5}, {2 * time.Minute, 8}}},
+		{"within a period", base.Add(time.Minute + 30*time.Second), []period{{time.Minute, 5}, {2 * time.Minute, 8}}},
+		{"current period", base.Add(2 * time.Minute), []period{{2 * time.Minute, 8}}},
+	}
+	for _, tt := range tests {
+		var got []period
+		for _, s := range m.Since(tt.since) {
+			if s.Mem != 2*s.Cpu {
+				t.Errorf("%s: the memory of %v is not averaged with the cpu", tt.name, s)
+			}
+			got = append(got, period{s.Time.Sub(base), s.Cpu})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Since = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsRecent(t *testing.T) {
+	now := time.Now()
+	m := NewMetrics()
+	for _, ago := range []time.Duration{3 * time.Minute, 50 * time.Second, 30 * time.Second, 10 * time.Second} {
+		m.record(MetricSample{Time: now.Add(-ago), Cpu: ago.Seconds()})
+	}
+	// Short windows get every sample rather than the per minute averages.
+	var got []float64
+	for _, s := range m.Since(now.Add(-time.Minute)) {
+		got = append(got, s.Cpu)
+	}
+	if want := []float64{50, 30, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Since = %v, want %v", got, want)
+	}
+}
@@ -13,10 +13,16 @@ type Options struct {
 	RetrySuccess      bool                  `json:"retry_success,omitempty"`       // If set the process will be restarted even if it exits with status 0.
 	RetryLimit        util.TimerRate        `json:"retry_limit,omitempty"`         // Maximum number of consequtive restarts within the period before the process is considered "errored".
 	MaxMemory         util.ParsableSize     `json:"max_memory,omitempty"`          // Maximum amount of memory the process is allowed to use, <= 0 means unlimited.
+	MaxCpu            float64               `json:"max_cpu,omitempty"`             // Maximum number of CPUs the process is allowed to use, <= 0 means unlimited.
+	MaxPids           int                   `json:"max_pids,omitempty"`            // Maximum number of processes and threads the process is allowed to create, <= 0 means unlimited.
+	IoWeight          int                   `json:"io_weight,omitempty"`           // The relative IO weight of the process in [1, 10000], <= 0 means the default of 100.
 	MinUptime         util.ParsableDuration `json:"min_uptime,omitempty"`          // Minimum uptime of the process before it is considered "started", <= 0 means immediate.
 	ExecTimeout       util.ParsableDuration `json:"exec_timeout,omitempty"`        // The time to wait for a process to exit before killing it, <= 0 means never.
 	StartTimeout      util.ParsableDuration `json:"start_timeout,omitempty"`       // The time to wait for a process to start before killing it, <= 0 means never.
 	StopTimeout       util.ParsableDuration `json:"stop_timeout"`                  // The time to wait for a process to stop before killing it, <= 0 means immediate.
+	Profile           string                `json:"env_profile,omitempty"`         // The environment profile, selects the env_<profile> variables of the processes.
+	InheritEnv        *bool                 `json:"inherit_env,omitempty"`         // Whether the processes start from the environment of the daemon, true by default.
+	StopGrace         time.Duration         `json:"-"`                             // The time the task needs to stop on top of the stop timeout, set by the task itself.
 }
 
 func (o *Options) WithDefaults() {
@@ -40,4 +46,17 @@ func (o *Options) WithDefaults() {
 	if !o.MinUptime.IsPositive() {
 		o.MinUptime = util.Duration(0)
 	}
+	if o.IoWeight > 0 {
+		o.IoWeight = min(o.IoWeight, 10000)
+	}
+}
+
+// Returns the time the worker waits for the task to stop before killing it.
+func (o Options) StopDeadline() util.ParsableDuration {
+	return util.Duration(o.StopTimeout.Duration + o.StopGrace)
+}
+
+// Returns whether any of the resource limits are set.
+func (o Options) HasLimits() bool {
+	return o.MaxMemory.IsPositive() || o.MaxCpu > 0 || o.MaxPids > 0 || o.IoWeight > 0
 }
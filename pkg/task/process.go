@@ -2,18 +2,29 @@ package task
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/samber/lo"
+	psnet "github.com/shirou/gopsutil/v3/net"
 	"github.com/shirou/gopsutil/v3/process"
 )
 
 type Report struct {
-	Pid        []int32   `json:"pid"`
-	Cpu        float64   `json:"cpu"`
-	Mem        float64   `json:"mem"`
-	Username   string    `json:"usr"`
-	CreateTime time.Time `json:"create_time"`
+	Pid            []int32   `json:"pid"`
+	Cpu            float64   `json:"cpu"`
+	Mem            float64   `json:"mem"`
+	Fds            int32     `json:"fds,omitempty"`
+	Threads        int32     `json:"threads,omitempty"`
+	ReadBytes      uint64    `json:"read_bytes,omitempty"`      // Bytes read from storage.
+	WriteBytes     uint64    `json:"write_bytes,omitempty"`     // Bytes written to storage.
+	CtxVoluntary   int64     `json:"ctx_voluntary,omitempty"`   // Voluntary context switches.
+	CtxInvoluntary int64     `json:"ctx_involuntary,omitempty"` // Involuntary context switches.
+	Ports          []string  `json:"ports,omitempty"`           // Listening sockets such as 0.0.0.0:80/tcp, only filled on demand.
+	Username       string    `json:"usr"`
+	CreateTime     time.Time `json:"create_time"`
 }
 
 func (r Report) String() string {
@@ -31,7 +42,7 @@ func (r Report) IsZero() bool {
 	return len(r.Pid) == 0
 }
 
-func fillReportRecursively(p *process.Process, into *Report) {
+func fillReport(p *process.Process, into *Report) {
 	if p == nil || p.Pid == 0 || lo.Contains(into.Pid, p.Pid) {
 		return
 	}
@@ -52,17 +63,70 @@ func fillReportRecursively(p *process.Process, into *Report) {
 	if mi, err := p.MemoryInfo(); err == nil && mi != nil {
 		into.Mem += float64(mi.RSS)
 	}
-	if children, err := p.Children(); err == nil {
-		for _, child := range children {
-			fillReportRecursively(child, into)
+	if n, err := p.NumFDs(); err == nil {
+		into.Fds += n
+	}
+	if n, err := p.NumThreads(); err == nil {
+		into.Threads += n
+	}
+	if io, err := p.IOCounters(); err == nil && io != nil {
+		into.ReadBytes += io.ReadBytes
+		into.WriteBytes += io.WriteBytes
+	}
+	if cs, err := p.NumCtxSwitches(); err == nil && cs != nil {
+		into.CtxVoluntary += cs.Voluntary
+		into.CtxInvoluntary += cs.Involuntary
+	}
+}
+
+// Returns the listening sockets of the processes, kept out of the reports sampled every
+// second as it scans every socket of the system.
+func ListeningPorts(pids []int32) (ports []string) {
+	for _, pid := range pids {
+		conns, err := psnet.ConnectionsPid("inet", pid)
+		if err != nil {
+			continue
+		}
+		for _, c := range conns {
+			if port := listeningPort(c); port != "" && !lo.Contains(ports, port) {
+				ports = append(ports, port)
+			}
 		}
 	}
+	return
+}
+
+// Formats the local address of a listening socket, returns an empty string for other sockets.
+func listeningPort(c psnet.ConnectionStat) string {
+	var proto string
+	switch {
+	case c.Type == syscall.SOCK_STREAM && c.Status == "LISTEN":
+		proto = "tcp"
+	case c.Type == syscall.SOCK_DGRAM && c.Raddr.IP == "":
+		proto = "udp"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(c.Laddr.IP, strconv.Itoa(int(c.Laddr.Port))), proto)
 }
 
 func InspectProcess(proc *process.Process) (r Report) {
 	if proc == nil || proc.Pid == 0 {
 		return
 	}
-	fillReportRecursively(proc, &r)
+	fillReport(proc, &r)
+	return
+}
+
+// Inspects every process in the group, the leader is reported first.
+func inspectGroup(g *procGroup) (r Report) {
+	if proc, err := process.NewProcess(int32(g.leader.Pid)); err == nil {
+		fillReport(proc, &r)
+	}
+	for _, pid := range g.Pids() {
+		if proc, err := process.NewProcess(pid); err == nil {
+			fillReport(proc, &r)
+		}
+	}
 	return
 }
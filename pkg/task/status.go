@@ -23,10 +23,12 @@ const (
 	Stopping     Status = iota | FlagAlive | FlagTransition
 	Running      Status = iota | FlagAlive
 	Retrying     Status = iota | FlagAlive
+	Unhealthy    Status = iota | FlagAlive
 	Errored      Status = iota | FlagError
 	TimeoutStop  Status = iota | FlagError
 	TimeoutStart Status = iota | FlagError
 	TimeoutExec  Status = iota | FlagError
+	OutOfMemory  Status = iota | FlagError
 )
 
 type statusDetail struct {
@@ -43,10 +45,12 @@ var statusDetails = map[Status]*statusDetail{
 	Stopping:     {"stopping", "👋", ""},
 	Running:      {"running", "🟢", ""},
 	Retrying:     {"retrying", "💤", "task is retrying"},
+	Unhealthy:    {"unhealthy", "🟠", "task is unhealthy"},
 	Errored:      {"errored", "🔴", "task errored"},
 	TimeoutStop:  {"timeout-stop", "🕛", "task timed out during exit"},
 	TimeoutStart: {"timeout-start", "🕛", "task timed out during launch"},
 	TimeoutExec:  {"timeout-exec", "🕛", "task execution timed out"},
+	OutOfMemory:  {"oom-killed", "💥", "task was killed for running out of memory"},
 }
 var statusByName = (func() (res map[string]Status) {
 	res = make(map[string]Status)
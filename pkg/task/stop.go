@@ -0,0 +1,150 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/can1357/gosu/pkg/util"
+)
+
+// A step of the stop sequence, the signal is sent once the wait since the previous step has passed.
+type StopStep struct {
+	Wait   util.ParsableDuration
+	Signal util.ParsableSignal
+}
+
+// Escalation sequence used to stop a process, written as alternating signals and waits
+// such as "TERM 10s INT 5s KILL". A trailing wait is followed by a KILL.
+type StopSequence []StopStep
+
+func (seq StopSequence) IsZero() bool {
+	return len(seq) == 0
+}
+
+// Returns the total time spent waiting between the steps.
+func (seq StopSequence) Duration() (d time.Duration) {
+	for _, step := range seq {
+		d += step.Wait.Duration
+	}
+	return
+}
+
+func (seq StopSequence) String() string {
+	var parts []string
+	for _, step := range seq {
+		if step.Wait.IsPositive() {
+			parts = append(parts, step.Wait.String())
+		}
+		parts = append(parts, step.Signal.String())
+	}
+	return strings.Join(parts, " ")
+}
+func (seq StopSequence) MarshalText() ([]byte, error) {
+	return []byte(seq.String()), nil
+}
+func (seq *StopSequence) UnmarshalText(text []byte) error {
+	fields := strings.FieldsFunc(string(text), func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '→' || r == '>'
+	})
+	var res StopSequence
+	var wait time.Duration
+	for _, field := range fields {
+		field = strings.TrimSuffix(field, "-")
+		if field == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(field); err == nil {
+			wait += d
+			continue
+		}
+		sig, err := util.ParseSignal(field)
+		if err != nil {
+			return err
+		}
+		res = append(res, StopStep{Wait: util.Duration(wait), Signal: sig})
+		wait = 0
+	}
+	if wait != 0 {
+		if len(res) == 0 {
+			return fmt.Errorf("stop sequence %q has no signals", string(text))
+		}
+		res = append(res, StopStep{Wait: util.Duration(wait), Signal: util.Signal(os.Kill)})
+	}
+	*seq = res
+	return nil
+}
+func (seq StopSequence) MarshalJSON() ([]byte, error) {
+	if seq.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(seq.String())
+}
+func (seq *StopSequence) UnmarshalJSON(text []byte) (err error) {
+	if len(text) == 0 || text[0] == 'n' {
+		return nil
+	}
+	var str string
+	if text[0] == '[' {
+		var parts []any
+		if err = json.Unmarshal(text, &parts); err != nil {
+			return
+		}
+		for _, part := range parts {
+			str += fmt.Sprint(part) + " "
+		}
+	} else if err = json.Unmarshal(text, &str); err != nil {
+		return
+	}
+	return seq.UnmarshalText([]byte(str))
+}
+
+// Runs the pre-stop hooks: the request to the path over the instance's IPC socket, then the
+// request to the URL or the shell command.
+func (h *processRunner) preStop(ctx Controller, env []string) {
+	var hooks []*HealthCheck
+	if h.PreStopPath != "" {
+		hooks = append(hooks, &HealthCheck{Http: h.PreStopPath, Timeout: ctx.Options().StopTimeout})
+	}
+	if hook := h.PreStop; strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://") {
+		hooks = append(hooks, &HealthCheck{Http: hook, Timeout: ctx.Options().StopTimeout})
+	} else if hook != "" {
+		hooks = append(hooks, &HealthCheck{Exec: hook, Timeout: ctx.Options().StopTimeout})
+	}
+	for _, hc := range hooks {
+		ctx.Logger().Printf("Running pre-stop hook.")
+		if err := hc.Probe(ctx, h, env); err != nil {
+			ctx.Logger().Printf("Pre-stop hook failed: %v", err)
+		}
+	}
+}
+
+// Returns the stop sequence of the task, defaulting to the stop signal.
+func (t *TaskRun) stopSequence() StopSequence {
+	if !t.StopSequence.IsZero() {
+		return t.StopSequence
+	}
+	return StopSequence{{Signal: util.Signal(t.StopSignal.Or(os.Interrupt))}}
+}
+
+// Leaves enough time for draining the proxy, the pre-stop hooks and the stop sequence to run before
+// the worker kills the process. The grace is set rather than added so that the instances, which
+// are configured again, end up with the same deadline.
+func (t *TaskRun) Configure(o *Options) {
+	extra := t.StopSequence.Duration()
+	if t.Proxy != nil {
+		extra += o.StopTimeout.Duration
+	}
+	if t.PreStop != "" {
+		extra += o.StopTimeout.Duration
+	}
+	if t.PreStopPath != "" {
+		extra += o.StopTimeout.Duration
+	}
+	o.StopGrace = extra
+}
+
+// The options are already configured by the parent task.
+func (h *processRunner) Configure(o *Options) {}
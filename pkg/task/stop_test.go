@@ -0,0 +1,69 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStopSequenceUnmarshalText(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string
+		duration time.Duration
+		wantErr  bool
+	}{
+		{in: "TERM", want: "TERM"},
+		{in: "TERM 10s INT 5s KILL", want: "TERM 10s INT 5s KILL", duration: 15 * time.Second},
+		{in: "sigterm 10s sigkill", want: "TERM 10s KILL", duration: 10 * time.Second},
+		{in: "TERM, 10s, KILL", want: "TERM 10s KILL", duration: 10 * time.Second},
+		{in: "TERM -> 10s -> KILL", want: "TERM 10s KILL", duration: 10 * time.Second},
+		{in: "TERM → 10s → KILL", want: "TERM 10s KILL", duration: 10 * time.Second},
+		{in: "15 2s 9", want: "TERM 2s KILL", duration: 2 * time.Second},
+		{in: "TERM 10s", want: "TERM 10s KILL", duration: 10 * time.Second},
+		{in: "TERM 5s 5s KILL", want: "TERM 10s KILL", duration: 10 * time.Second},
+		{in: "1s TERM", want: "1s TERM", duration: time.Second},
+		{in: "", want: ""},
+		{in: "10s", wantErr: true},
+		{in: "TERM 10s NOPE", wantErr: true},
+	}
+	for _, tt := range tests {
+		var seq StopSequence
+		err := seq.UnmarshalText([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q): err = %v, want error = %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := seq.String(); got != tt.want {
+			t.Errorf("UnmarshalText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := seq.Duration(); got != tt.duration {
+			t.Errorf("UnmarshalText(%q): duration = %v, want %v", tt.in, got, tt.duration)
+		}
+	}
+}
+
+func TestStopSequenceJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"TERM 10s KILL"`, `"TERM 10s KILL"`},
+		{`["TERM", "10s", "KILL"]`, `"TERM 10s KILL"`},
+		{`["TERM", "10s", 9]`, `"TERM 10s KILL"`},
+		{`null`, `null`},
+	}
+	for _, tt := range tests {
+		var seq StopSequence
+		if err := json.Unmarshal([]byte(tt.in), &seq); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if got, _ := json.Marshal(seq); string(got) != tt.want {
+			t.Errorf("round trip of %s = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
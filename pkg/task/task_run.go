@@ -2,11 +2,13 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/can1357/gosu/pkg/automarshal"
@@ -16,27 +18,73 @@ import (
 	"github.com/can1357/gosu/pkg/settings"
 	"github.com/can1357/gosu/pkg/util"
 	"github.com/samber/lo"
-	"github.com/shirou/gopsutil/v3/process"
 )
 
 const inspectRate = 1 * time.Second
 
 type TaskRun struct {
-	Foreign string            `json:"-"`               // The foreign language to run.
-	Exec    string            `json:"exec,omitempty"`  // The executable used to run the script.
-	Args    []string          `json:"args,omitempty"`  // Arguments passed.
-	Cwd     string            `json:"cwd"`             // Working directory.
-	Env     map[string]string `json:"env,omitempty"`   // The environment variables to set.
-	N       int               `json:"n,omitempty"`     // The number of instances to launch, >1 will run as cluster with special env.
-	Proxy   *revproxy.Options `json:"proxy,omitempty"` // The proxy options.
+	Foreign string            `json:"-"`                // The foreign language to run.
+	Exec    string            `json:"exec,omitempty"`   // The executable used to run the script.
+	Args    []string          `json:"args,omitempty"`   // Arguments passed.
+	Cwd     string            `json:"cwd"`              // Working directory.
+	Env     map[string]string `json:"env,omitempty"`    // The environment variables to set.
+	N       int               `json:"n,omitempty"`      // The number of instances to launch, >1 will run as cluster with special env.
+	Proxy   *revproxy.Options `json:"proxy,omitempty"`  // The proxy options.
+	Health  *HealthCheck      `json:"health,omitempty"` // The health check, replaces the default startup check if set.
 
+	StopSignal   util.ParsableSignal `json:"stop_signal,omitempty"`   // The signal sent to stop the process, defaults to SIGINT.
+	StopSequence StopSequence        `json:"stop_sequence,omitempty"` // The escalation sequence used to stop the process, overrides the stop signal.
+	PreStop      string              `json:"pre_stop,omitempty"`      // The shell command to run or URL to request before stopping the process.
+	PreStopPath  string              `json:"pre_stop_path,omitempty"` // The path to request over the instance's IPC socket before stopping it, requires a proxy.
+
+	Profiles  map[string]map[string]string `json:"-"`                    // The env_<profile> variables laid over env when the profile is selected.
+	EnvFile   EnvFiles                     `json:"env_file,omitempty"`   // Dotenv files loaded before env, relative to the working directory.
+	ExpandEnv bool                         `json:"expand_env,omitempty"` // Expands ${NAME} and ${NAME:-default} references in env, the profiles and the env files.
 }
 
 type processRunner struct {
 	*TaskRun
-	lb  *revproxy.LoadBalancer
-	n   int
-	ipc string
+	lb         *revproxy.LoadBalancer
+	n          int
+	ipc        string
+	active     atomic.Bool   // Whether the runner currently owns its slot in the cluster.
+	ready      chan struct{} // Closed once the instance is serving.
+	readyOnce  sync.Once     //
+	retire     chan struct{} // Closed to drain and stop the instance during a reload.
+	retireOnce sync.Once     //
+}
+
+var errRetired = NonRetriable(errors.New("instance retired"))
+
+func (h *processRunner) markReady() {
+	h.readyOnce.Do(func() { close(h.ready) })
+}
+func (h *processRunner) markRetired() {
+	h.active.Store(false)
+	h.retireOnce.Do(func() { close(h.retire) })
+}
+func (h *processRunner) retired() bool {
+	select {
+	case <-h.retire:
+		return true
+	default:
+		return false
+	}
+}
+
+// Waits for the connections of an upstream removed from the load balancer to finish.
+func drainUpstream(ctx Controller, upstream *revproxy.Upstream) {
+	deadline := time.After(ctx.Options().StopTimeout.Duration)
+	for upstream.Connections() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			ctx.Logger().Printf("Upstream %v still has %d connections, stopping anyway.", upstream, upstream.Connections())
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
 }
 
 func lifecheck(adr string) bool {
@@ -53,7 +101,18 @@ func lifecheck(adr string) bool {
 	return strings.Contains(string(buf[:n]), "HTTP/1.1")
 }
 
+// Checks whether the instance is up, using the health check if there is one.
+func (h *processRunner) alive(ctx context.Context, env []string) bool {
+	if h.Health != nil {
+		return h.Health.Probe(ctx, h, env) == nil
+	}
+	return lifecheck(h.ipc)
+}
+
 func (h *processRunner) Launch(ctx Controller) <-chan error {
+	if h.retired() {
+		return lo.Async(func() error { return errRetired })
+	}
 	var err error
 	var cmd *exec.Cmd
 	if flavor := h.Foreign; flavor == "" || flavor == "run" {
@@ -65,9 +124,8 @@ func (h *processRunner) Launch(ctx Controller) <-chan error {
 		}
 	}
 	cmd.Dir = h.Cwd
-	cmd.Env = os.Environ()
-	for k, v := range h.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	if cmd.Env, err = h.Environ(ctx.Options()); err != nil {
+		return lo.Async(func() error { return err })
 	}
 	cmd.Env = append(cmd.Env, "GOSU_NS="+ctx.Namespace())
 	cmd.Env = append(cmd.Env, "GOSU_CID="+fmt.Sprintf("%d", h.n))
@@ -80,33 +138,140 @@ func (h *processRunner) Launch(ctx Controller) <-chan error {
 	cmd.Stdout = ctx.Logger().Stdout()
 	cmd.Stderr = ctx.Logger().Stderr()
 
+	prepareGroup(cmd)
+
+	// Place the process in its own cgroup to enforce the resource limits.
+	var cg *cgroup
+	if ctx.Options().HasLimits() {
+		cg, err = newCgroup(ctx.Namespace(), ctx.Options())
+		if err != nil {
+			ctx.Logger().Printf("Failed to create cgroup, resource limits will not be enforced: %v", err)
+			cg = nil
+		} else {
+			cg.Attach(cmd)
+		}
+	}
+
 	err = cmd.Start()
 	if err != nil {
+		if cg != nil {
+			cg.Close()
+		}
 		return lo.Async(func() error { return err })
 	}
+	if cg != nil {
+		if err := cg.Started(cmd.Process.Pid); err != nil {
+			ctx.Logger().Printf("Failed to move the process into its cgroup, resource limits will not be enforced: %v", err)
+			cg.Close()
+			cg = nil
+		}
+	}
+	ctx.SetMemoryEnforced(cg != nil)
+
+	group := newProcGroup(cmd, cg, ctx.Namespace())
+	resultDone := make(chan struct{})
 
 	// Start the inspector.
 	//
 	done := false
-	proc, _ := process.NewProcess(int32(cmd.Process.Pid))
+	ctx.OnSignal(func(sig os.Signal) error {
+		if done {
+			return Idle
+		}
+		return group.Signal(sig)
+	})
 	go func() {
 		for !done {
-			ctx.Report(InspectProcess(proc))
+			ctx.Report(inspectGroup(group))
 			time.Sleep(inspectRate)
 		}
 		ctx.Report(Report{})
 	}()
+	// Stops the process gracefully, escalating through the stop sequence and killing it if it is
+	// still alive after the stop timeout.
+	var stopOnce sync.Once
+	terminate := func() {
+		stopOnce.Do(func() {
+			exited := func() bool {
+				select {
+				case <-resultDone:
+					return true
+				default:
+					return false
+				}
+			}
+			go func() {
+				if exited() {
+					return
+				}
+				h.preStop(ctx, cmd.Env)
+				for _, step := range h.stopSequence() {
+					if step.Wait.IsPositive() {
+						select {
+						case <-resultDone:
+							return
+						case <-step.Wait.After():
+						}
+					}
+					if exited() {
+						return
+					}
+					ctx.Logger().Printf("Sending %s.", step.Signal)
+					group.Signal(step.Signal.Signal)
+				}
+				select {
+				case <-resultDone:
+				case <-ctx.Options().StopTimeout.After():
+					group.Kill()
+				}
+			}()
+		})
+	}
+	var serving atomic.Pointer[revproxy.Upstream]
+	removeUpstream := func() *revproxy.Upstream {
+		upstream := serving.Swap(nil)
+		if upstream != nil {
+			ctx.Logger().Printf("Removing upstream %v", upstream)
+			h.lb.RemoveUpstream(upstream)
+		}
+		return upstream
+	}
 	go func() {
-		<-ctx.Stopping()
-		if runtime.GOOS == "windows" {
-			cmd.Process.Signal(os.Kill)
-		} else {
-			cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-ctx.Stopping():
+			removeUpstream()
+			terminate()
+		case <-h.retire:
+			if upstream := removeUpstream(); upstream != nil {
+				drainUpstream(ctx, upstream)
+			}
+			terminate()
 		}
 	}()
+	var failure atomic.Pointer[error]
 	resultChanel := lo.Async(func() error {
+		defer close(resultDone)
 		err := cmd.Wait()
 		done = true
+		if leftover := group.Close(); len(leftover) != 0 {
+			ctx.Logger().Printf("Killed %d leftover process(es): %v", len(leftover), leftover)
+		}
+		if cg != nil {
+			if n := cg.OomKills(); n != 0 {
+				ctx.Logger().Printf("%d process(es) were killed for running out of memory.", n)
+				if err != nil {
+					err = fmt.Errorf("%w: %w", OutOfMemory, err)
+				} else {
+					err = OutOfMemory
+				}
+			}
+			cg.Close()
+		}
+		if h.retired() {
+			return errRetired
+		} else if f := failure.Load(); f != nil {
+			return *f
+		}
 		return err
 	})
 
@@ -121,7 +286,7 @@ func (h *processRunner) Launch(ctx Controller) <-chan error {
 				select {
 				case <-ctx.Done():
 					return lo.Async(func() error { return ctx.Err() })
-				case ok := <-lo.Async(func() bool { return lifecheck(h.ipc) }):
+				case ok := <-lo.Async(func() bool { return h.alive(ctx, cmd.Env) }):
 					if ok {
 						ctx.Logger().Printf("Server started.")
 						upstream = revproxy.NewIpcUpstream(ctx.Namespace(), h.ipc)
@@ -141,12 +306,63 @@ func (h *processRunner) Launch(ctx Controller) <-chan error {
 		if upstream != nil {
 			ctx.Logger().Printf("Adding upstream %v", upstream)
 			h.lb.AddUpstream(upstream)
-			go func() {
-				<-ctx.Stopping()
-				ctx.Logger().Printf("Removing upstream %v", upstream)
-				h.lb.RemoveUpstream(upstream)
-			}()
+			serving.Store(upstream)
+
+			// If we were stopped while adding the upstream, take it out again.
+			select {
+			case <-ctx.Stopping():
+				removeUpstream()
+			case <-h.retire:
+				removeUpstream()
+			default:
+			}
+			h.markReady()
 		}
+	} else {
+		h.markReady()
+	}
+
+	// Keep probing the instance for its whole lifetime.
+	if hc := h.Health; hc != nil {
+		go func() {
+			failures := 0
+			for !done {
+				select {
+				case <-ctx.Stopping():
+					return
+				case <-h.retire:
+					return
+				case <-hc.Interval.After():
+				}
+				err := hc.Probe(ctx, h, cmd.Env)
+				if err == nil {
+					if failures != 0 {
+						ctx.Logger().Printf("Health check recovered.")
+						ctx.SetHealthy(true)
+						if upstream := serving.Load(); upstream != nil {
+							h.lb.AddUpstream(upstream)
+						}
+					}
+					failures = 0
+					continue
+				}
+
+				failures++
+				ctx.Logger().Printf("Health check failed (%d/%d): %v", failures, hc.Threshold, err)
+				if failures == 1 {
+					ctx.SetHealthy(false)
+					if upstream := serving.Load(); upstream != nil {
+						h.lb.RemoveUpstream(upstream)
+					}
+				}
+				if failures >= hc.Threshold {
+					err = fmt.Errorf("health check failed %d times: %w", failures, err)
+					failure.Store(&err)
+					terminate()
+					return
+				}
+			}
+		}()
 	}
 	return resultChanel
 }
@@ -156,6 +372,7 @@ func (h *TaskRun) Launch(ctx Controller) <-chan error {
 	if h.Proxy != nil {
 		ctx.Logger().Printf("Starting proxy.")
 		lb = revproxy.NewLoadBalancer(*h.Proxy)
+		lb.Name = ctx.Namespace()
 		go func() {
 			err := lb.Listen()
 			if err != nil && ctx.Err() == nil {
@@ -166,23 +383,73 @@ func (h *TaskRun) Launch(ctx Controller) <-chan error {
 	}
 
 	newRunner := func(n int) *processRunner {
-		r := &processRunner{TaskRun: h, lb: lb, n: n}
+		r := &processRunner{TaskRun: h, lb: lb, n: n, ready: make(chan struct{}), retire: make(chan struct{})}
 		return r
 	}
 
-	if h.N <= 1 {
+	if h.N <= 1 && lb == nil {
 		pr := newRunner(0)
-		return lo.Async(func() error {
-			if lb != nil {
-				defer func() {
-					ctx.Logger().Printf("Stopping proxy.")
-					lb.Close()
-				}()
-			}
-			return <-pr.Launch(ctx)
-		})
+		return pr.Launch(ctx)
 	} else {
 		pipe := newPipeController(ctx)
+		n := max(h.N, 1)
+		slots := make([]*processRunner, n)
+		spawn := func(r *processRunner) (exited <-chan struct{}) {
+			ch := make(chan struct{})
+			go func() {
+				defer close(ch)
+				task := Task{
+					ID: automarshal.ID{
+						Kind: "",
+						ID:   fmt.Sprintf("%d", r.n),
+					},
+					ITask: r,
+				}
+				select {
+				case <-pipe.Done():
+					return
+				case err := <-pipe.launch(task):
+					if !r.active.Load() {
+						return
+					}
+					if err != nil || pipe.left.Add(-1) == 0 {
+						pipe.cancel(err)
+					}
+				}
+			}()
+			return ch
+		}
+
+		// Replaces the instances one by one, only retiring the old one once the new one is serving.
+		var reloadMu sync.Mutex
+		reload := func() error {
+			if lb == nil {
+				return ErrReloadUnsupported
+			}
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+			for i := range slots {
+				ctx.Logger().Printf("Reloading instance %d.", i)
+				next := newRunner(i)
+				exited := spawn(next)
+				select {
+				case <-pipe.Done():
+					return util.Cause(pipe)
+				case <-exited:
+					return fmt.Errorf("instance %d exited during reload", i)
+				case <-time.After(h.reloadTimeout(ctx.Options())):
+					next.markRetired()
+					return fmt.Errorf("instance %d timed out during reload", i)
+				case <-next.ready:
+				}
+				next.active.Store(true)
+				slots[i].markRetired()
+				slots[i] = next
+			}
+			ctx.Logger().Printf("Reload complete.")
+			return nil
+		}
+
 		return lo.Async(func() error {
 			if lb != nil {
 				defer func() {
@@ -190,27 +457,13 @@ func (h *TaskRun) Launch(ctx Controller) <-chan error {
 					lb.Close()
 				}()
 			}
-			pipe.left.Store(int32(h.N))
-			for i := 0; i < h.N && pipe.Err() == nil; i++ {
-				i := i
-				go func() {
-					task := Task{
-						ID: automarshal.ID{
-							Kind: "",
-							ID:   fmt.Sprintf("%d", i),
-						},
-						ITask: newRunner(i),
-					}
-					select {
-					case <-pipe.Done():
-						return
-					case err := <-pipe.launch(task):
-						if err != nil || pipe.left.Add(-1) == 0 {
-							pipe.cancel(err)
-						}
-					}
-				}()
+			pipe.left.Store(int32(n))
+			for i := 0; i < n && pipe.Err() == nil; i++ {
+				slots[i] = newRunner(i)
+				slots[i].active.Store(true)
+				spawn(slots[i])
 			}
+			ctx.OnReload(reload)
 			<-pipe.Done()
 			if e := util.Cause(pipe); e != nil {
 				return NonRetriable(e)
@@ -220,10 +473,36 @@ func (h *TaskRun) Launch(ctx Controller) <-chan error {
 	}
 }
 
+// The time a replacement has to become ready during a reload: the start timeout if set, otherwise
+// a minute or the failure window of the health check if it is longer.
+func (t *TaskRun) reloadTimeout(o Options) time.Duration {
+	if o.StartTimeout.IsPositive() {
+		return o.StartTimeout.Duration
+	}
+	timeout := time.Minute
+	if t.Health != nil {
+		timeout = max(timeout, time.Duration(t.Health.Threshold)*(t.Health.Interval.Duration+t.Health.Timeout.Duration))
+	}
+	return timeout
+}
+
 func (t *TaskRun) WithDefaults() {
 	if t.Cwd == "" {
 		t.Cwd, _ = os.Getwd()
 	}
+	if t.Health != nil {
+		t.Health.WithDefaults()
+	}
+}
+
+func (t *TaskRun) Validate() error {
+	if t.Health != nil && t.Proxy == nil && t.Health.viaProxy() {
+		return fmt.Errorf("health check path %s requires a proxy, use a URL, tcp or exec instead", t.Health.Http)
+	}
+	if t.PreStopPath != "" && t.Proxy == nil {
+		return fmt.Errorf("pre-stop path %s requires a proxy, use pre_stop with a URL or command instead", t.PreStopPath)
+	}
+	return nil
 }
 
 func (h *TaskRun) UnmarshalInline(text string) (err error) {
@@ -48,6 +48,19 @@ func (w Whiteboard) Clear() {
 		return true
 	})
 }
+
+// Calls the function for each field, keys are relative to the whiteboard.
+func (w Whiteboard) Range(fn func(key string, value json.RawMessage) bool) {
+	if w.data == nil {
+		return
+	}
+	w.data.Range(func(key, value any) bool {
+		if k, ok := key.(string); ok && strings.HasPrefix(k, w.prefix) {
+			return fn(k[len(w.prefix):], value.([]byte))
+		}
+		return true
+	})
+}
 func (w Whiteboard) Fork(key string) Whiteboard {
 	if key == "" || w.data == nil {
 		return w
@@ -2,10 +2,14 @@ package task
 
 import (
 	"context"
+	"errors"
+	"os"
 
 	"github.com/can1357/gosu/pkg/clog"
 )
 
+var ErrReloadUnsupported = errors.New("task does not support reloading")
+
 type StatusOrError = error
 type Worker interface {
 	Task() Task
@@ -14,19 +18,29 @@ type Worker interface {
 	Logger() *clog.Logger
 	Status() StatusOrError
 	Whiteboard() *Whiteboard
+	Metrics() *Metrics
 
 	context.Context
 	Inspect() Report
 	Run() error
 	Stop()
 	Kill()
+	Reload() error
+	Signal(os.Signal) error
 	Traverse(func(Worker) bool)
 }
+type Launcher interface {
+	Launch(ctx context.Context, subtask Task, modifiers ...func(*Options)) <-chan error
+}
 type Controller interface {
 	Worker
+	Launcher
 	Report(Report)
 	Stopping() <-chan struct{}
-	Launch(ctx context.Context, subtask Task, modifiers ...func(*Options)) <-chan error
+	OnReload(func() error)
+	OnSignal(func(os.Signal) error)
+	SetHealthy(bool)
+	SetMemoryEnforced(bool)
 }
 
 func NewWorker(ctx context.Context, task Task, options Options) (w Worker) {
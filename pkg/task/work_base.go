@@ -12,12 +12,14 @@ type workerBase struct {
 	options         Options      // The options.
 	logger          *clog.Logger // The logger.
 	whiteboard      Whiteboard   // The whiteboard.
+	metrics         *Metrics     // The resource usage history, kept across retries.
 }
 
 func newWorkerBase(ctx context.Context, task Task, options Options) (m *workerBase) {
 	m = &workerBase{
 		task:    task,
 		options: options,
+		metrics: NewMetrics(),
 	}
 	m.logger = clog.FromContext(ctx)
 	m.whiteboard = WhiteboardFromContext(ctx)
@@ -45,6 +47,9 @@ func (work *workerBase) Logger() *clog.Logger {
 func (work *workerBase) Namespace() string {
 	return work.logger.Namespace // TODO: FIX THIS
 }
+func (work *workerBase) Metrics() *Metrics {
+	return work.metrics
+}
 func (work *workerBase) Whiteboard() *Whiteboard {
 	return &work.whiteboard
 }
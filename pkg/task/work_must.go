@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,13 +16,17 @@ import (
 // Defines a non-retriable work.
 type mustWorker struct {
 	*workerBase
-	context.Context                         // The context of the runner.
-	cancel          context.CancelCauseFunc //
-	stopChannel     chan struct{}           // The channel to stop the runner.
-	stopChannelUsed atomic.Bool             // Whether the stop channel has been used.
-	report          atomic.Value            // The stats to be reported by the runner.
-	status          Status                  // The current status (if alive).
-	children        sync.Map                // The children workers.
+	context.Context                                       // The context of the runner.
+	cancel          context.CancelCauseFunc               //
+	stopChannel     chan struct{}                         // The channel to stop the runner.
+	stopChannelUsed atomic.Bool                           // Whether the stop channel has been used.
+	report          atomic.Value                          // The stats to be reported by the runner.
+	status          Status                                // The current status (if alive).
+	children        sync.Map                              // The children workers.
+	reload          atomic.Pointer[func() error]          // The reload handler installed by the task.
+	signal          atomic.Pointer[func(os.Signal) error] // The signal handler installed by the task.
+	unhealthy       atomic.Bool                           // Whether the health check of the task is failing.
+	memoryEnforced  atomic.Bool                           // Whether the task enforces the memory limit itself.
 }
 
 func newMustWorker(m *workerBase) *mustWorker {
@@ -45,6 +50,7 @@ func (work *mustWorker) Inspect() Report {
 }
 func (work *mustWorker) Report(r Report) {
 	work.report.Store(r)
+	work.metrics.Add(r)
 }
 func (work *mustWorker) Stopping() <-chan struct{} {
 	return work.stopChannel
@@ -65,6 +71,59 @@ func (work *mustWorker) Launch(ctx context.Context, subtask Task, modifiers ...f
 		return w.Run()
 	})
 }
+func (work *mustWorker) SetHealthy(healthy bool) {
+	work.unhealthy.Store(!healthy)
+}
+func (work *mustWorker) SetMemoryEnforced(enforced bool) {
+	work.memoryEnforced.Store(enforced)
+}
+func (work *mustWorker) OnReload(fn func() error) {
+	work.reload.Store(&fn)
+}
+func (work *mustWorker) Reload() error {
+	if fn := work.reload.Load(); fn != nil {
+		return (*fn)()
+	}
+
+	// Reload the children instead, if any of them support it.
+	supported := false
+	var errs []error
+	work.Traverse(func(w Worker) bool {
+		if err := w.Reload(); !errors.Is(err, ErrReloadUnsupported) {
+			supported = true
+			errs = append(errs, err)
+		}
+		return true
+	})
+	if !supported {
+		return ErrReloadUnsupported
+	}
+	return errors.Join(errs...)
+}
+func (work *mustWorker) OnSignal(fn func(os.Signal) error) {
+	work.signal.Store(&fn)
+}
+func (work *mustWorker) Signal(sig os.Signal) error {
+	delivered := false
+	var errs []error
+	if fn := work.signal.Load(); fn != nil {
+		if err := (*fn)(sig); !errors.Is(err, Idle) {
+			delivered = true
+			errs = append(errs, err)
+		}
+	}
+	work.Traverse(func(w Worker) bool {
+		if err := w.Signal(sig); !errors.Is(err, Idle) {
+			delivered = true
+			errs = append(errs, err)
+		}
+		return true
+	})
+	if !delivered {
+		return Idle
+	}
+	return errors.Join(errs...)
+}
 func (work *mustWorker) Traverse(fn func(Worker) bool) {
 	work.children.Range(func(key, value interface{}) bool {
 		w := key.(Worker)
@@ -75,9 +134,9 @@ func (work *mustWorker) Traverse(fn func(Worker) bool) {
 func (work *mustWorker) signalStop(wait bool) {
 	work.status = Stopping
 	enforceOrCancel := func() {
-		if work.options.StopTimeout.IsPositive() {
+		if deadline := work.options.StopDeadline(); deadline.IsPositive() {
 			select {
-			case <-work.options.StopTimeout.After():
+			case <-deadline.After():
 				work.cancel(TimeoutStop)
 			case <-work.Done():
 				break
@@ -89,7 +148,7 @@ func (work *mustWorker) signalStop(wait bool) {
 
 	if !work.stopChannelUsed.Swap(true) {
 		close(work.stopChannel)
-		if !work.options.StopTimeout.IsPositive() {
+		if !work.options.StopDeadline().IsPositive() {
 			work.cancel(Cancelled)
 		} else if wait {
 			enforceOrCancel()
@@ -108,6 +167,9 @@ func (work *mustWorker) Status() error {
 			return c
 		}
 	} else if work.status.IsAlive() {
+		if work.status == Running && work.unhealthy.Load() {
+			return Unhealthy
+		}
 		return work.status
 	} else {
 		return Idle
@@ -130,7 +192,7 @@ func (work *mustWorker) Run() error {
 		}()
 	}
 
-	// Enforce max memory.
+	// Enforce max memory, unless the kernel does it for us.
 	if work.options.MaxMemory.IsPositive() {
 		go func() {
 			for {
@@ -138,9 +200,12 @@ func (work *mustWorker) Run() error {
 				case <-work.Done():
 					return
 				case <-time.After(time.Second * 30):
+					if work.memoryEnforced.Load() {
+						continue
+					}
 					report := work.Inspect()
 					if report.Mem > float64(work.options.MaxMemory.Value) {
-						work.cancel(errors.New("memory limit exceeded"))
+						work.cancel(fmt.Errorf("%w: memory limit exceeded", OutOfMemory))
 						return
 					}
 				}
@@ -167,7 +232,7 @@ func (work *mustWorker) Run() error {
 			work.cancel(TimeoutExec)
 			return TimeoutExec
 		case err := <-exitReason:
-			if err != nil && StatusFromErr(err) != Errored {
+			if err != nil && !StatusFromErr(err).IsError() {
 				err = fmt.Errorf("%w: %s", Errored, err.Error())
 			}
 			work.cancel(err)
@@ -177,7 +242,7 @@ func (work *mustWorker) Run() error {
 	case err := <-exitReason:
 		if err == nil {
 			err = fmt.Errorf("%w: quit too early", Errored)
-		} else if StatusFromErr(err) != Errored {
+		} else if !StatusFromErr(err).IsError() {
 			err = fmt.Errorf("%w: %s", Errored, err.Error())
 		}
 		work.cancel(err)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"sync/atomic"
 	"time"
 
@@ -27,10 +28,11 @@ type retryWorker struct {
 	retryState      atomic.Uint64              // Number of errors so far encountered && tick
 	retryCancel     chan struct{}              // The channel to cancel the retrier.
 	status          Status                     // The current status (if alive).
+	onExit          ExitHook                   // The hook to record exits with.
 }
 
 func newRetryWorker(m *workerBase) (w *retryWorker) {
-	w = &retryWorker{workerBase: m, retryCancel: make(chan struct{}, 1)}
+	w = &retryWorker{workerBase: m, retryCancel: make(chan struct{}, 1), onExit: ExitHookFromContext(m)}
 	w.Context, w.cancel = util.WithCancelOrOk(m)
 	return
 }
@@ -59,6 +61,24 @@ func (retry *retryWorker) Traverse(fn func(Worker) bool) {
 		m.Traverse(fn)
 	}
 }
+func (retry *retryWorker) Reload() error {
+	if m := retry.must.Load(); m != nil {
+		return m.Reload()
+	}
+	return Idle
+}
+func (retry *retryWorker) Signal(sig os.Signal) error {
+	if m := retry.must.Load(); m != nil {
+		return m.Signal(sig)
+	}
+	return Idle
+}
+func (retry *retryWorker) Launch(ctx context.Context, subtask Task, modifiers ...func(*Options)) <-chan error {
+	if m := retry.must.Load(); m != nil {
+		return m.Launch(ctx, subtask, modifiers...)
+	}
+	return lo.Async(func() error { return Idle })
+}
 
 // Retry state.
 func unpackErrorState(state uint64) (tick uint32, counter uint32) {
@@ -81,6 +101,10 @@ func (retry *retryWorker) disableRetries() {
 		}
 	}
 }
+func (retry *retryWorker) stopped() bool {
+	tick, _ := unpackErrorState(retry.retryState.Load())
+	return tick == retryTickDead
+}
 func (retry *retryWorker) retriable(err error) bool {
 	if err != nil {
 		if errors.Is(err, ErrNonRetriable) {
@@ -188,8 +212,15 @@ func (retry *retryWorker) Run() (err error) {
 		}
 		retry.status = work.status
 
-		// If error is not retriable, exit.
-		if !retry.tryRetry(err) {
+		// If error is not retriable, exit, record the crash unless we were asked to stop or the
+		// instance was retired by a reload.
+		exit := NewExit(retry.Namespace(), err)
+		stopped := retry.stopped() || errors.Is(err, errRetired)
+		exit.Restarted = retry.tryRetry(err)
+		if !stopped && (err != nil || exit.Restarted) {
+			retry.onExit(exit)
+		}
+		if !exit.Restarted {
 			break
 		}
 	}
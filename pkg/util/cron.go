@@ -0,0 +1,268 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression.
+//
+// Accepts the standard 5-field form (minute hour dom month dow), the 6-field form with a leading
+// seconds field, the @yearly/@monthly/@weekly/@daily/@hourly shorthands and an optional
+// "CRON_TZ=<zone>" or "TZ=<zone>" prefix.
+type CronSchedule struct {
+	Expr     string
+	Location *time.Location
+	second   uint64
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	anyDay   bool // Either dom or dow is a wildcard, so both must match.
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSecond = cronField{0, 59, nil}
+	cronMinute = cronField{0, 59, nil}
+	cronHour   = cronField{0, 23, nil}
+	cronDom    = cronField{1, 31, nil}
+	cronMonth  = cronField{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cron value: %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("cron value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Parses a single field into a bitmask, returns whether the field was a wildcard.
+func (f cronField) parse(expr string) (bits uint64, wildcard bool, err error) {
+	for _, part := range strings.Split(expr, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		lo, hi := f.min, f.max
+		switch rng {
+		case "*", "?":
+			wildcard = !hasStep
+		default:
+			first, last, isRange := strings.Cut(rng, "-")
+			if lo, err = f.value(first); err != nil {
+				return
+			}
+			if isRange {
+				if hi, err = f.value(last); err != nil {
+					return
+				}
+			} else if !hasStep {
+				hi = lo
+			}
+		}
+		inc := 1
+		if hasStep {
+			if inc, err = strconv.Atoi(step); err != nil || inc <= 0 {
+				return 0, false, fmt.Errorf("invalid cron step: %q", step)
+			}
+		}
+		if lo > hi {
+			return 0, false, fmt.Errorf("invalid cron range: %q", rng)
+		}
+		for i := lo; i <= hi; i += inc {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func ParseCron(expr string) (s CronSchedule, err error) {
+	err = s.UnmarshalText([]byte(expr))
+	return
+}
+
+func (s CronSchedule) IsZero() bool {
+	return s.Expr == ""
+}
+func (s CronSchedule) String() string {
+	return s.Expr
+}
+
+// Returns the first activation time strictly after t, or the zero time if there is none.
+func (s CronSchedule) Next(t time.Time) time.Time {
+	if s.IsZero() {
+		return time.Time{}
+	}
+	origin := t.Location()
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	loc := t.Location()
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + 5
+
+wrap:
+	for t.Year() <= limit {
+		for s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			if t.Month() == time.January {
+				continue wrap
+			}
+		}
+		for !s.matchDay(t) {
+			month := t.Month()
+			t = cronAdvance(t, 1, 0)
+			if t.Month() != month {
+				continue wrap
+			}
+		}
+		for s.hour&(1<<uint(t.Hour())) == 0 {
+			day := t.Day()
+			t = cronAdvance(t, 0, t.Hour()+1)
+			if t.Day() != day {
+				continue wrap
+			}
+		}
+		for s.minute&(1<<uint(t.Minute())) == 0 {
+			hour := t.Hour()
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			if t.Hour() != hour {
+				continue wrap
+			}
+		}
+		for s.second&(1<<uint(t.Second())) == 0 {
+			minute := t.Minute()
+			t = t.Add(time.Second)
+			if t.Minute() != minute {
+				continue wrap
+			}
+		}
+		return t.In(origin)
+	}
+	return time.Time{}
+}
+
+// Returns the start of the given hour of the day after the given number of days, or of the first
+// hour after it that exists if a clock change skips it. time.Date normalizes a skipped hour to
+// one of its neighbours, which may be before t and would keep Next from making progress.
+func cronAdvance(t time.Time, days, hour int) time.Time {
+	for {
+		next := time.Date(t.Year(), t.Month(), t.Day()+days, hour, 0, 0, 0, t.Location())
+		if next.After(t) {
+			return next
+		}
+		hour++
+	}
+}
+func (s CronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s CronSchedule) MarshalText() ([]byte, error) {
+	return []byte(s.Expr), nil
+}
+func (s *CronSchedule) UnmarshalText(text []byte) (err error) {
+	expr := strings.TrimSpace(string(text))
+	*s = CronSchedule{Expr: expr}
+	if expr == "" {
+		return errors.New("empty cron expression")
+	}
+
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		zone, rest, _ := strings.Cut(expr, " ")
+		_, zone, _ = strings.Cut(zone, "=")
+		if s.Location, err = time.LoadLocation(zone); err != nil {
+			return
+		}
+		expr = strings.TrimSpace(rest)
+	}
+	if full, ok := cronShorthands[strings.ToLower(expr)]; ok {
+		expr = full
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return fmt.Errorf("invalid cron expression %q: expected 5 or 6 fields, got %d", s.Expr, len(fields))
+	}
+
+	var domAny, dowAny bool
+	if s.second, _, err = cronSecond.parse(fields[0]); err != nil {
+		return
+	}
+	if s.minute, _, err = cronMinute.parse(fields[1]); err != nil {
+		return
+	}
+	if s.hour, _, err = cronHour.parse(fields[2]); err != nil {
+		return
+	}
+	if s.dom, domAny, err = cronDom.parse(fields[3]); err != nil {
+		return
+	}
+	if s.month, _, err = cronMonth.parse(fields[4]); err != nil {
+		return
+	}
+	if s.dow, dowAny, err = cronDow.parse(fields[5]); err != nil {
+		return
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is an alias for sunday.
+	}
+	s.anyDay = domAny || dowAny
+	return nil
+}
+
+func (s CronSchedule) MarshalJSON() ([]byte, error) {
+	if s.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.Expr)
+}
+func (s *CronSchedule) UnmarshalJSON(text []byte) (err error) {
+	if len(text) == 0 || text[0] == 'n' {
+		return nil
+	}
+	var str string
+	if err = json.Unmarshal(text, &str); err != nil {
+		return
+	}
+	return s.UnmarshalText([]byte(str))
+}
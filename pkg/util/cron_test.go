@@ -0,0 +1,133 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"* * * * *", true},
+		{"*/5 * * * * *", true},
+		{"0 3 * * mon-fri", true},
+		{"0 0 1 jan,jul *", true},
+		{"@daily", true},
+		{"@Hourly", true},
+		{"CRON_TZ=Europe/Berlin 0 3 * * *", true},
+		{"TZ=UTC @weekly", true},
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"x * * * *", false},
+		{"CRON_TZ=Nowhere/City 0 3 * * *", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseCron(%q): err = %v, want ok = %v", tt.expr, err, tt.ok)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	at := func(loc *time.Location, s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		expr string
+		loc  *time.Location
+		from string
+		want string
+	}{
+		{"every minute", "* * * * *", time.UTC, "2024-01-01 10:00:00", "2024-01-01 10:01:00"},
+		{"strictly after", "0 3 * * *", time.UTC, "2024-01-01 03:00:00", "2024-01-02 03:00:00"},
+		{"seconds field", "*/15 * * * * *", time.UTC, "2024-01-01 10:00:14", "2024-01-01 10:00:15"},
+		{"step", "*/20 * * * *", time.UTC, "2024-01-01 10:41:00", "2024-01-01 11:00:00"},
+		{"range", "0 9-17 * * *", time.UTC, "2024-01-01 17:30:00", "2024-01-02 09:00:00"},
+		{"list", "0 0 1,15 * *", time.UTC, "2024-01-02 00:00:00", "2024-01-15 00:00:00"},
+		{"month names", "0 0 1 jul *", time.UTC, "2024-01-01 00:00:00", "2024-07-01 00:00:00"},
+		{"year wrap", "0 0 1 1 *", time.UTC, "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		{"leap day", "0 0 29 2 *", time.UTC, "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"no such day", "0 0 30 2 *", time.UTC, "2024-01-01 00:00:00", ""},
+		{"weekday", "0 8 * * mon", time.UTC, "2024-01-03 00:00:00", "2024-01-08 08:00:00"},
+		{"weekday range", "0 8 * * mon-fri", time.UTC, "2024-01-06 00:00:00", "2024-01-08 08:00:00"},
+		{"sunday as 7", "0 0 * * 7", time.UTC, "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"weekday list", "0 0 * * sat,sun", time.UTC, "2024-01-01 00:00:00", "2024-01-06 00:00:00"},
+		// Either day of month or day of week matches when both are restricted.
+		{"dom or dow", "0 0 13 * fri", time.UTC, "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"dom or dow, dom first", "0 0 13 * fri", time.UTC, "2024-01-12 00:00:00", "2024-01-13 00:00:00"},
+		// Both have to match when one of them is a wildcard.
+		{"dom with any dow", "0 0 13 * *", time.UTC, "2024-01-01 00:00:00", "2024-01-13 00:00:00"},
+		{"dow with any dom", "0 0 * * fri", time.UTC, "2024-01-06 00:00:00", "2024-01-12 00:00:00"},
+		{"stepped dom restricts", "0 0 */10 * fri", time.UTC, "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"shorthand", "@monthly", time.UTC, "2024-01-15 12:00:00", "2024-02-01 00:00:00"},
+		{"time zone prefix", "CRON_TZ=America/New_York 0 3 * * *", time.UTC, "2024-01-01 00:00:00", "2024-01-01 08:00:00"},
+		// 02:30 does not exist the day the clocks move forward, so that day is skipped.
+		{"dst gap", "30 2 * * *", newYork, "2024-03-10 00:00:00", "2024-03-11 02:30:00"},
+		{"dst gap, hourly", "0 * * * *", newYork, "2024-03-10 01:30:00", "2024-03-10 03:00:00"},
+		{"dst gap, midnight", "0 0 * * *", newYork, "2024-03-09 23:00:00", "2024-03-10 00:00:00"},
+		// 01:30 happens twice when the clocks move back, it fires on the first one.
+		{"dst overlap", "30 1 * * *", newYork, "2024-11-03 00:00:00", "2024-11-03 01:30:00"},
+		{"dst hourly", "0 * * * *", newYork, "2024-11-03 00:30:00", "2024-11-03 01:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(at(tt.loc, tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next = %v, want none", got)
+				}
+				return
+			}
+			if want := at(tt.loc, tt.want); !got.Equal(want) {
+				t.Errorf("Next = %v, want %v", got, want)
+			}
+			if got.Location() != tt.loc {
+				t.Errorf("Next is in %v, want %v", got.Location(), tt.loc)
+			}
+		})
+	}
+}
+
+func TestCronJSON(t *testing.T) {
+	var s CronSchedule
+	if err := s.UnmarshalJSON([]byte(`"@daily"`)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.MarshalJSON(); string(data) != `"@daily"` {
+		t.Errorf("MarshalJSON = %s, want the expression", data)
+	}
+	var zero CronSchedule
+	if err := zero.UnmarshalJSON([]byte(`null`)); err != nil || !zero.IsZero() {
+		t.Errorf("UnmarshalJSON(null) = %v, %v", zero, err)
+	}
+	if data, _ := zero.MarshalJSON(); string(data) != "null" {
+		t.Errorf("MarshalJSON of zero = %s, want null", data)
+	}
+	if !zero.Next(time.Now()).IsZero() {
+		t.Error("zero schedule has a next activation")
+	}
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type ParsableSignal struct {
+	os.Signal
+}
+
+// Parses a signal from its name with or without the SIG prefix, or from its number.
+func ParseSignal(name string) (ParsableSignal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if n, err := strconv.Atoi(name); err == nil {
+		return ParsableSignal{syscall.Signal(n)}, nil
+	}
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signalByName(name); ok {
+		return ParsableSignal{sig}, nil
+	}
+	return ParsableSignal{}, fmt.Errorf("unknown signal: %q", name)
+}
+func Signal(sig os.Signal) ParsableSignal {
+	return ParsableSignal{sig}
+}
+
+func (s ParsableSignal) IsZero() bool {
+	return s.Signal == nil
+}
+func (s ParsableSignal) Or(def os.Signal) os.Signal {
+	if s.IsZero() {
+		return def
+	}
+	return s.Signal
+}
+
+// Short name of the signal without the SIG prefix.
+func (s ParsableSignal) String() string {
+	if s.IsZero() {
+		return ""
+	}
+	if name, ok := signalName(s.Signal); ok {
+		return strings.TrimPrefix(name, "SIG")
+	}
+	return s.Signal.String()
+}
+func (s ParsableSignal) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+func (s *ParsableSignal) UnmarshalText(text []byte) (err error) {
+	*s, err = ParseSignal(string(text))
+	return
+}
+func (s ParsableSignal) MarshalJSON() ([]byte, error) {
+	if s.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.String())
+}
+func (s *ParsableSignal) UnmarshalJSON(text []byte) (err error) {
+	if len(text) == 0 || text[0] == 'n' {
+		return nil
+	}
+	if text[0] != '"' {
+		var n int
+		if err = json.Unmarshal(text, &n); err != nil {
+			return
+		}
+		*s = ParsableSignal{syscall.Signal(n)}
+		return
+	}
+	var str string
+	if err = json.Unmarshal(text, &str); err != nil {
+		return
+	}
+	return s.UnmarshalText([]byte(str))
+}
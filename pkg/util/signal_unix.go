@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func signalByName(name string) (os.Signal, bool) {
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, true
+	}
+	return nil, false
+}
+func signalName(sig os.Signal) (string, bool) {
+	if s, ok := sig.(syscall.Signal); ok {
+		if name := unix.SignalName(s); name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+var signalNames = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  os.Interrupt,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": os.Kill,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+func signalByName(name string) (os.Signal, bool) {
+	sig, ok := signalNames[name]
+	return sig, ok
+}
+func signalName(sig os.Signal) (string, bool) {
+	for name, s := range signalNames {
+		if s == sig {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -84,26 +84,30 @@ func (d ParsableSize) IsZero() bool {
 func (d ParsableSize) IsPositive() bool {
 	return d.Value > 0
 }
+
+// Units used when formatting, largest first.
+var formatUnits = []struct {
+	name string
+	mult int
+}{
+	{"gib", 1 << 30}, {"gb", 1e9},
+	{"mib", 1 << 20}, {"mb", 1e6},
+	{"kib", 1 << 10}, {"kb", 1e3},
+}
+
 func (d ParsableSize) String() string {
 	if d.Value < 0 {
 		return "-" + ParsableSize{-d.Value}.String()
 	} else if d.Value == 0 {
 		return "0"
-	} else {
-		if d.Value <= 1024 {
-			return fmt.Sprintf("%db", d.Value)
-		}
-		d.Value >>= 10
-		if d.Value <= 1024 {
-			return fmt.Sprintf("%dkb", d.Value)
-		}
-		d.Value >>= 10
-		if d.Value <= 1024 {
-			return fmt.Sprintf("%dmb", d.Value)
+	}
+	// Use the largest unit that represents the value exactly so that it survives a round trip.
+	for _, u := range formatUnits {
+		if d.Value%u.mult == 0 {
+			return fmt.Sprintf("%d%s", d.Value/u.mult, u.name)
 		}
-		d.Value >>= 10
-		return fmt.Sprintf("%dgb", d.Value)
 	}
+	return fmt.Sprintf("%db", d.Value)
 }
 func (d ParsableSize) MarshalText() ([]byte, error) {
 	return []byte(d.String()), nil
@@ -155,7 +159,7 @@ func (d *ParsableSize) UnmarshalJSON(text []byte) (err error) {
 		if err != nil {
 			return
 		}
-		err = d.UnmarshalText(text)
+		err = d.UnmarshalText([]byte(str))
 		return
 	} else {
 		var fp float64
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// Parses a point in time given either as a date or as a duration ago such as 10m or 2d.
+func ParseTime(s string) (t time.Time, err error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return
+		}
+	}
+	var ago time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n float64
+		n, err = strconv.ParseFloat(days, 64)
+		ago = time.Duration(n * float64(24*time.Hour))
+	} else {
+		ago, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+	return time.Now().Add(-ago), nil
+}